@@ -0,0 +1,100 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+const (
+	// dhcpRangeStartOffset and dhcpRangeEndOffset delimit the default
+	// DHCP range within the ProvisioningNetworkCIDR.
+	dhcpRangeStartOffset = 10
+	dhcpRangeEndOffset   = 100
+)
+
+// SetDefaults fills in the values the installer is allowed to leave
+// empty, so that every consumer of the Provisioning spec sees the same
+// fully populated configuration. It is safe to call more than once.
+func (prov *Provisioning) SetDefaults() {
+	spec := &prov.Spec
+
+	spec.ProvisioningNetwork = prov.NetworkMode()
+
+	if prov.NetworkMode() == ProvisioningNetworkDisabled && spec.ExternalHTTPIP == "" {
+		spec.ExternalHTTPIP = spec.ExternalIronicIP
+	}
+
+	if prov.DHCPServer() == DHCPServerInternal && spec.ProvisioningDHCPRange == "" {
+		// An unusable CIDR is left for validation to report.
+		if dhcpRange, err := defaultDHCPRange(spec.ProvisioningNetworkCIDR); err == nil {
+			spec.ProvisioningDHCPRange = dhcpRange
+		}
+	}
+}
+
+// EffectiveConfig returns the network mode and the DHCP range of a
+// defaulted spec, with the range in its canonical "start,end" form.
+func (prov *Provisioning) EffectiveConfig() *EffectiveProvisioningConfig {
+	config := &EffectiveProvisioningConfig{
+		ProvisioningNetwork: prov.NetworkMode(),
+	}
+
+	if prov.DHCPServer() == DHCPServerInternal {
+		if start, end, err := parseDHCPRange(prov.Spec.ProvisioningDHCPRange); err == nil {
+			config.ProvisioningDHCPRange = fmt.Sprintf("%s,%s", start, end)
+		}
+	}
+	return config
+}
+
+// defaultDHCPRange returns the range that goes from the 10th to the
+// 100th address of the given network, like the installer does. IPv6
+// networks use the same offsets, so that the addresses picked low in
+// the network for the provisioning IP and the hosts stay out of it.
+func defaultDHCPRange(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	start := addToIP(network.IP, dhcpRangeStartOffset)
+	end := addToIP(network.IP, dhcpRangeEndOffset)
+	if !network.Contains(start) || !network.Contains(end) {
+		return "", fmt.Errorf("network %q is too small for the default DHCP range", cidr)
+	}
+	return fmt.Sprintf("%s,%s", start, end), nil
+}
+
+// addToIP returns the address offset addresses after ip, keeping the
+// length of the original address.
+func addToIP(ip net.IP, offset int64) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), big.NewInt(offset))
+
+	result := make(net.IP, len(ip))
+	if sum.Sign() < 0 || len(sum.Bytes()) > len(result) {
+		// Left the address space, return an address that no network
+		// contains.
+		return nil
+	}
+	copy(result[len(result)-len(sum.Bytes()):], sum.Bytes())
+	return result
+}
@@ -0,0 +1,158 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDefaults(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     ProvisioningSpec
+		expected ProvisioningSpec
+	}{
+		{
+			name: "EmptyNetworkIsManaged",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.10,172.30.20.100",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "DHCPExternalIsUnmanaged",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+				ProvisioningNetwork:      ProvisioningNetworkUnmanaged,
+			},
+		},
+		{
+			name: "ExplicitNetworkWins",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+				ProvisioningNetwork:      ProvisioningNetworkDisabled,
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+				ProvisioningNetwork:      ProvisioningNetworkDisabled,
+			},
+		},
+		{
+			name: "DisabledExternalHTTPIP",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+				ExternalHTTPIP:      "192.168.111.10",
+			},
+		},
+		{
+			name: "ExistingDHCPRangeKept",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.50,172.30.20.60",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.50,172.30.20.60",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "SubnetTooSmall",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/26",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0/26",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "IPv6",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/64",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/64",
+				ProvisioningDHCPRange:   "fd00:1101::a,fd00:1101::64",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "IPv6SmallSubnet",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/120",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/120",
+				ProvisioningDHCPRange:   "fd00:1101::a,fd00:1101::64",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "IPv6SubnetTooSmall",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/125",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "fd00:1101::/125",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+		{
+			name: "InvalidCIDR",
+			spec: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0",
+			},
+			expected: ProvisioningSpec{
+				ProvisioningNetworkCIDR: "172.30.20.0",
+				ProvisioningNetwork:     ProvisioningNetworkManaged,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &Provisioning{Spec: tc.spec}
+			prov.Default()
+			assert.Equal(t, tc.expected, prov.Spec)
+
+			// Defaulting is idempotent
+			prov.SetDefaults()
+			assert.Equal(t, tc.expected, prov.Spec)
+		})
+	}
+}
+
+func TestDefaultedIPv6SpecIsValid(t *testing.T) {
+	for _, ip := range []string{"fd00:1101::3", "fd00:1101::ffff:ffff:ffff:fff0"} {
+		t.Run(ip, func(t *testing.T) {
+			prov := &Provisioning{
+				Spec: ProvisioningSpec{
+					ProvisioningInterface:   "eth1",
+					ProvisioningIP:          ip,
+					ProvisioningNetworkCIDR: "fd00:1101::/64",
+				},
+			}
+			prov.Default()
+			assert.Equal(t, "fd00:1101::a,fd00:1101::64", prov.Spec.ProvisioningDHCPRange)
+			assert.NoError(t, prov.ValidateBaremetalProvisioningConfig())
+		})
+	}
+}
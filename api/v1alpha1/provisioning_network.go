@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// DHCPServer identifies what hands out addresses on the provisioning
+// network.
+type DHCPServer string
+
+const (
+	// DHCPServerInternal means the metal3 pod runs a DHCP server
+	DHCPServerInternal DHCPServer = "Internal"
+
+	// DHCPServerExternal means DHCP is provided outside of the cluster
+	DHCPServerExternal DHCPServer = "External"
+
+	// DHCPServerNone means there is no provisioning network to serve
+	DHCPServerNone DHCPServer = "None"
+)
+
+// NetworkMode returns the effective state of the provisioning network.
+// When ProvisioningNetwork is not set it is derived from the deprecated
+// ProvisioningDHCPExternal field.
+func (prov *Provisioning) NetworkMode() ProvisioningNetwork {
+	if prov.Spec.ProvisioningNetwork != "" {
+		return prov.Spec.ProvisioningNetwork
+	}
+	if prov.Spec.ProvisioningDHCPExternal {
+		return ProvisioningNetworkUnmanaged
+	}
+	return ProvisioningNetworkManaged
+}
+
+// DHCPServer returns which DHCP server serves the provisioning network.
+func (prov *Provisioning) DHCPServer() DHCPServer {
+	switch prov.NetworkMode() {
+	case ProvisioningNetworkManaged:
+		return DHCPServerInternal
+	case ProvisioningNetworkUnmanaged:
+		return DHCPServerExternal
+	default:
+		return DHCPServerNone
+	}
+}
+
+// PXEAllowed reports whether hosts may PXE boot from the provisioning
+// network. Without one, only virtual media can be used.
+func (prov *Provisioning) PXEAllowed() bool {
+	return prov.NetworkMode() != ProvisioningNetworkDisabled
+}
+
+// ProvisioningInterfaceRequired reports whether the metal3 pod needs a
+// NIC on the provisioning network.
+func (prov *Provisioning) ProvisioningInterfaceRequired() bool {
+	return prov.NetworkMode() != ProvisioningNetworkDisabled
+}
@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkModeHelpers(t *testing.T) {
+	testCases := []struct {
+		name                string
+		spec                ProvisioningSpec
+		expectedMode        ProvisioningNetwork
+		expectedDHCPServer  DHCPServer
+		expectedPXE         bool
+		expectedNICRequired bool
+	}{
+		{
+			name:                "Default",
+			spec:                ProvisioningSpec{},
+			expectedMode:        ProvisioningNetworkManaged,
+			expectedDHCPServer:  DHCPServerInternal,
+			expectedPXE:         true,
+			expectedNICRequired: true,
+		},
+		{
+			name:                "LegacyDHCPExternal",
+			spec:                ProvisioningSpec{ProvisioningDHCPExternal: true},
+			expectedMode:        ProvisioningNetworkUnmanaged,
+			expectedDHCPServer:  DHCPServerExternal,
+			expectedPXE:         true,
+			expectedNICRequired: true,
+		},
+		{
+			name:                "Managed",
+			spec:                ProvisioningSpec{ProvisioningNetwork: ProvisioningNetworkManaged},
+			expectedMode:        ProvisioningNetworkManaged,
+			expectedDHCPServer:  DHCPServerInternal,
+			expectedPXE:         true,
+			expectedNICRequired: true,
+		},
+		{
+			name:                "Unmanaged",
+			spec:                ProvisioningSpec{ProvisioningNetwork: ProvisioningNetworkUnmanaged},
+			expectedMode:        ProvisioningNetworkUnmanaged,
+			expectedDHCPServer:  DHCPServerExternal,
+			expectedPXE:         true,
+			expectedNICRequired: true,
+		},
+		{
+			name:                "Disabled",
+			spec:                ProvisioningSpec{ProvisioningNetwork: ProvisioningNetworkDisabled, ProvisioningDHCPExternal: true},
+			expectedMode:        ProvisioningNetworkDisabled,
+			expectedDHCPServer:  DHCPServerNone,
+			expectedPXE:         false,
+			expectedNICRequired: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &Provisioning{Spec: tc.spec}
+			assert.Equal(t, tc.expectedMode, prov.NetworkMode())
+			assert.Equal(t, tc.expectedDHCPServer, prov.DHCPServer())
+			assert.Equal(t, tc.expectedPXE, prov.PXEAllowed())
+			assert.Equal(t, tc.expectedNICRequired, prov.ProvisioningInterfaceRequired())
+		})
+	}
+}
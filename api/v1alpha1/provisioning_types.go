@@ -16,9 +16,80 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProvisioningSingletonName is the name of the provisioning resource
+	ProvisioningSingletonName string = "provisioning-configuration"
+
+	// ImmutableFieldsOverrideAnnotation allows changing Provisioning
+	// fields that are otherwise immutable once set. Its value records
+	// the reason for the change and must not be empty. The operator
+	// removes it once the change has been applied, so it has to be set
+	// in the same update as the change, and records the change in the
+	// immutableFields status.
+	ImmutableFieldsOverrideAnnotation string = "metal3.io/immutable-fields-override"
+
+	// ImmutableFieldsOverridden is the status condition reporting that
+	// the ImmutableFieldsOverrideAnnotation is set on the resource.
+	ImmutableFieldsOverridden string = "ImmutableFieldsOverridden"
+
+	// ConditionValid reports whether the spec passed validation.
+	ConditionValid string = "Valid"
 
-	operatorv1 "github.com/openshift/api/operator/v1"
+	// ConditionDeployed reports whether the metal3 deployment has been
+	// created or updated from the current spec.
+	ConditionDeployed string = "Deployed"
+
+	// ConditionReady reports whether the metal3 deployment is available.
+	ConditionReady string = "Ready"
+
+	// ConditionIgnored is set on Provisioning objects which are not
+	// named ProvisioningSingletonName, and so are not acted upon.
+	ConditionIgnored string = "Ignored"
+
+	// RotateCredentialsAnnotation requests a rotation of the mariadb
+	// password and of the credentials of the ironic and
+	// ironic-inspector APIs whenever it is set to a value that was not
+	// acted upon yet, such as the date of the request.
+	RotateCredentialsAnnotation string = "metal3.io/rotate-credentials"
+)
+
+// CredentialsRotationPhase is the step of an ongoing rotation of the
+// credentials of the metal3 services.
+type CredentialsRotationPhase string
+
+const (
+	// CredentialsRotationAccepting means the APIs are being restarted
+	// to accept the new credentials along with the old ones.
+	CredentialsRotationAccepting CredentialsRotationPhase = "AcceptingNewCredentials"
+
+	// CredentialsRotationSwitching means the clients are being
+	// restarted with the new credentials.
+	CredentialsRotationSwitching CredentialsRotationPhase = "SwitchingToNewCredentials"
+
+	// CredentialsRotationRevoking means the APIs are being restarted to
+	// stop accepting the old credentials.
+	CredentialsRotationRevoking CredentialsRotationPhase = "RevokingOldCredentials"
+)
+
+// ProvisioningNetwork is the state of the provisioning network
+// +kubebuilder:validation:Enum=Managed;Unmanaged;Disabled
+type ProvisioningNetwork string
+
+const (
+	// ProvisioningNetworkManaged means the provisioning network is
+	// completely managed by the Baremetal IPI solution
+	ProvisioningNetworkManaged ProvisioningNetwork = "Managed"
+
+	// ProvisioningNetworkUnmanaged means the provisioning network is
+	// present and used, but DHCP is managed by the user
+	ProvisioningNetworkUnmanaged ProvisioningNetwork = "Unmanaged"
+
+	// ProvisioningNetworkDisabled means there is no provisioning network
+	ProvisioningNetworkDisabled ProvisioningNetwork = "Disabled"
 )
 
 // ProvisioningSpec defines the desired state of Provisioning
@@ -28,6 +99,14 @@ type ProvisioningSpec struct {
 	// have values like eth1 or ens3.
 	ProvisioningInterface string `json:"provisioningInterface,omitempty"`
 
+	// ProvisioningMacAddresses are the MAC addresses of the network
+	// interfaces connecting the baremetal servers to the provisioning
+	// network. On each server, the interface whose MAC address is in
+	// the list is used, which allows interface names to differ between
+	// servers. When set, it takes precedence over provisioningInterface.
+	// +optional
+	ProvisioningMacAddresses []string `json:"provisioningMacAddresses,omitempty"`
+
 	// ProvisioningIP is the IP address assigned to the
 	// provisioningInterface of the baremetal server. This IP
 	// address should be within the provisioning subnet, and
@@ -36,7 +115,10 @@ type ProvisioningSpec struct {
 
 	// ProvisioningNetworkCIDR is the network on which the
 	// baremetal nodes are provisioned. The provisioningIP and the
-	// IPs in the dhcpRange all come from within this network.
+	// IPs in the dhcpRange all come from within this network. It can
+	// be an IPv4 or an IPv6 network, in which case DHCPv6 is used and
+	// its prefix length must be at least 64. The provisioning network
+	// of a dual-stack cluster uses a single address family.
 	ProvisioningNetworkCIDR string `json:"provisioningNetworkCIDR,omitempty"`
 
 	// ProvisioningDHCPExternal indicates whether the DHCP server
@@ -57,9 +139,9 @@ type ProvisioningSpec struct {
 	// internal DHCP server and the value of ProvisioningDHCPRange
 	// is not set, then the DHCP range is taken to be the default
 	// range which goes from .10 to .100 of the
-	// ProvisioningNetworkCIDR. This is the only value in all of
-	// the Provisioning configuration that can be changed after
-	// the installer has created the CR. This value needs to be
+	// ProvisioningNetworkCIDR, or from its 10th to its 100th
+	// address for IPv6. Like the network mode, it can be
+	// changed after the installer has created the CR. This value needs to be
 	// two comma sererated IP addresses within the
 	// ProvisioningNetworkCIDR where the 1st address represents
 	// the start of the range and the 2nd address represents the
@@ -83,15 +165,241 @@ type ProvisioningSpec struct {
 	// bring up the baremetal cluster using virtual media or assisted
 	// installation. If using metal3 for power management, BMCs must be
 	// accessible from the machine networks. User should provide two IPs on
-	// the external network that would be used for provisioning services.
-	ProvisioningNetwork string `json:"provisioningNetwork,omitempty"`
+	// the external network that would be used for provisioning services,
+	// in externalIronicIP and externalHTTPIP.
+	// When not set, it defaults to `Unmanaged` if ProvisioningDHCPExternal
+	// is true and to `Managed` otherwise. It can be changed on a running
+	// cluster, the metal3 pod is then rolled out with the new topology.
+	ProvisioningNetwork ProvisioningNetwork `json:"provisioningNetwork,omitempty"`
+
+	// ExternalIronicIP is the address on the machine network that the
+	// ironic and ironic-inspector APIs listen on when
+	// ProvisioningNetwork is `Disabled`. It is ignored otherwise.
+	// +optional
+	ExternalIronicIP string `json:"externalIronicIP,omitempty"`
+
+	// ExternalHTTPIP is the address on the machine network that the
+	// httpd serving iPXE scripts and OS images listens on when
+	// ProvisioningNetwork is `Disabled`. It defaults to externalIronicIP
+	// and is ignored in the other modes.
+	// +optional
+	ExternalHTTPIP string `json:"externalHTTPIP,omitempty"`
+
+	// NodePlacement controls the nodes the metal3 pod and the OS image
+	// cache run on. It defaults to the control plane nodes.
+	// +optional
+	NodePlacement *NodePlacement `json:"nodePlacement,omitempty"`
+}
+
+// NodePlacement describes the nodes the metal3 workloads are scheduled
+// on. As the metal3 pod uses the host network, those nodes must be
+// attached to the provisioning network when there is one.
+type NodePlacement struct {
+	// NodeSelector is the node selector of the metal3 workloads. When
+	// empty, they run on the control plane nodes.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are the tolerations of the metal3 workloads. When
+	// empty, they tolerate the taints of the control plane nodes.
+	// Setting them replaces that default, which needs to be listed
+	// again when the control plane nodes are still wanted.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// EffectiveProvisioningConfig is the part of the configuration that
+// the operator resolves from defaults or deprecated fields.
+type EffectiveProvisioningConfig struct {
+	// ProvisioningNetwork is the state of the provisioning network the
+	// operator acts upon.
+	ProvisioningNetwork ProvisioningNetwork `json:"provisioningNetwork,omitempty"`
+
+	// ProvisioningDHCPRange is the range served by the DHCP server
+	// running within the metal3 cluster, as two comma separated IP
+	// addresses. It is empty when that DHCP server is not used.
+	ProvisioningDHCPRange string `json:"provisioningDHCPRange,omitempty"`
+}
+
+// CredentialsRotationStatus describes the rotations of the credentials
+// of the metal3 services requested with RotateCredentialsAnnotation.
+type CredentialsRotationStatus struct {
+	// Request is the value of the annotation last acted upon.
+	// +optional
+	Request string `json:"request,omitempty"`
+
+	// Phase is the step of the ongoing rotation, it is empty when
+	// none is in progress.
+	// +optional
+	Phase CredentialsRotationPhase `json:"phase,omitempty"`
+
+	// LastRotationTime is when the last rotation completed.
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
+// ImageCachePhase is the state of the OS image on the image cache.
+type ImageCachePhase string
+
+const (
+	// ImageCacheDownloading means the image is being downloaded on at
+	// least one node
+	ImageCacheDownloading ImageCachePhase = "Downloading"
+	// ImageCacheVerifying means the checksum of the image is being
+	// verified on at least one node
+	ImageCacheVerifying ImageCachePhase = "Verifying"
+	// ImageCacheDownloaded means every node caches a verified image
+	ImageCacheDownloaded ImageCachePhase = "Downloaded"
+	// ImageCacheFailed means the image could not be downloaded or did
+	// not match its checksum on at least one node
+	ImageCacheFailed ImageCachePhase = "Failed"
+)
+
+// ImageCacheStatus describes the cache of the OS image on the control
+// plane nodes.
+type ImageCacheStatus struct {
+	// Phase is the state of the image on the nodes.
+	// +optional
+	Phase ImageCachePhase `json:"phase,omitempty"`
+
+	// URL is where deployments fetch the image from.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// CachedNodes is the number of nodes caching a verified image.
+	CachedNodes int32 `json:"cachedNodes"`
+
+	// DesiredNodes is the number of nodes the image is cached on.
+	DesiredNodes int32 `json:"desiredNodes"`
+
+	// DownloadedBytes is how much of the image the nodes downloaded so
+	// far, added up over the nodes.
+	// +optional
+	DownloadedBytes int64 `json:"downloadedBytes,omitempty"`
+
+	// TotalBytes is the size of the image added up over the nodes, as
+	// far as the server tells it.
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+
+	// SHA256 is the checksum the nodes verified the image against, once
+	// every one of them caches it.
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Message tells why the phase is not Downloaded.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// TLSStatus describes the certificates securing the ironic and
+// ironic-inspector APIs.
+type TLSStatus struct {
+	// CAExpiry is when the CA issuing the serving certificate expires.
+	// +optional
+	CAExpiry *metav1.Time `json:"caExpiry,omitempty"`
+
+	// CertificateExpiry is when the serving certificate expires. It is
+	// re-issued well before.
+	// +optional
+	CertificateExpiry *metav1.Time `json:"certificateExpiry,omitempty"`
+}
+
+// EndpointsStatus describes where the clients running in the cluster
+// reach the ironic and ironic-inspector APIs.
+type EndpointsStatus struct {
+	// Ironic is the URL of the ironic API, through its Service.
+	// +optional
+	Ironic string `json:"ironic,omitempty"`
+
+	// Inspector is the URL of the ironic-inspector API, through its
+	// Service.
+	// +optional
+	Inspector string `json:"inspector,omitempty"`
+}
+
+// ImmutableFieldsStatus is the audit record of the changes made to the
+// fields that are immutable once set.
+type ImmutableFieldsStatus struct {
+	// Applied are the values of the immutable fields last acted upon by
+	// the operator, by field name, which changes are recorded against.
+	// Lists are comma separated.
+	// +optional
+	Applied map[string]string `json:"applied,omitempty"`
+
+	// LastOverride is the last change made to the immutable fields.
+	// +optional
+	LastOverride *ImmutableFieldsOverride `json:"lastOverride,omitempty"`
+}
+
+// ImmutableFieldsOverride describes a change made to the immutable
+// fields with the metal3.io/immutable-fields-override annotation.
+type ImmutableFieldsOverride struct {
+	// Reason is the value of the annotation. It is empty when the change
+	// was not made through the admission webhook.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Time is when the operator acted upon the change.
+	Time metav1.Time `json:"time"`
+
+	// Changes describe every field that was changed, with its previous
+	// and its new value.
+	Changes []string `json:"changes"`
 }
 
 // ProvisioningStatus defines the observed state of Provisioning
 type ProvisioningStatus struct {
-	operatorv1.OperatorStatus `json:",inline"`
+	// ObservedGeneration is the most recent generation of the spec
+	// acted upon by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// EffectiveConfig is the configuration applied by the operator
+	// once defaults have been filled in.
+	// +optional
+	EffectiveConfig *EffectiveProvisioningConfig `json:"effectiveConfig,omitempty"`
+
+	// ImmutableFields records the changes made to the fields that are
+	// immutable once set.
+	// +optional
+	ImmutableFields *ImmutableFieldsStatus `json:"immutableFields,omitempty"`
+
+	// CredentialsRotation describes the rotations of the credentials of
+	// the metal3 services.
+	// +optional
+	CredentialsRotation *CredentialsRotationStatus `json:"credentialsRotation,omitempty"`
+
+	// TLS describes the certificates securing the ironic and
+	// ironic-inspector APIs.
+	// +optional
+	TLS *TLSStatus `json:"tls,omitempty"`
+
+	// ImageCache describes the cache of the OS image on the control
+	// plane nodes.
+	// +optional
+	ImageCache *ImageCacheStatus `json:"imageCache,omitempty"`
+
+	// Endpoints describes where the clients running in the cluster
+	// reach the ironic and ironic-inspector APIs. They are also
+	// published in the metal3-ironic-endpoints ConfigMap of the
+	// namespace of the metal3 pod.
+	// +optional
+	Endpoints *EndpointsStatus `json:"endpoints,omitempty"`
+
+	// Conditions describe the validity of the configuration and the
+	// state of the metal3 deployment built from it.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=provisionings,scope=Cluster
+// +kubebuilder:storageversion
+
 // Provisioning contains configuration used by the Provisioning
 // service (Ironic) to provision baremetal hosts.
 // Provisioning is created by the OpenShift installer using admin or
@@ -100,12 +408,10 @@ type ProvisioningStatus struct {
 // This CR is a singleton, created by the installer and currently only
 // consumed by the cluster-baremetal-operator to bring up and update
 // containers in a metal3 cluster.
-// +kubebuilder:subresource:status
-// +kubebuilder:resource:path=provisionings,scope=Cluster
-
-// +kubebuilder:object:root=true
-
-// Provisioning is the Schema for the provisionings API
+// Once set, fields other than ProvisioningNetwork, ProvisioningDHCPRange,
+// ProvisioningDHCPExternal and NodePlacement can only be changed together
+// with the metal3.io/immutable-fields-override annotation, whose use is
+// recorded as an event and a status condition.
 type Provisioning struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
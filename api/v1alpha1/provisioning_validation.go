@@ -0,0 +1,393 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// minDHCPv6PrefixLength is the shortest network prefix dnsmasq
+	// accepts for a DHCPv6 range.
+	minDHCPv6PrefixLength = 64
+
+	// osImageChecksumParam is the query parameter of
+	// ProvisioningOSDownloadURL carrying the sha256 of the image.
+	osImageChecksumParam = "sha256"
+)
+
+// sha256Checksum matches a hex encoded sha256.
+var sha256Checksum = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// ValidateBaremetalProvisioningConfig checks that the network
+// settings in the Provisioning spec are consistent with each other.
+// The returned error names every field that failed validation.
+func (prov *Provisioning) ValidateBaremetalProvisioningConfig() error {
+	spec := prov.Spec
+	var errs []error
+
+	if err := prov.validateOSDownloadURL(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := prov.validateNodePlacement(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch prov.NetworkMode() {
+	case ProvisioningNetworkManaged, ProvisioningNetworkUnmanaged:
+	case ProvisioningNetworkDisabled:
+		// When the provisioning network is disabled there is no
+		// provisioning subnet, the services listen on the machine network.
+		errs = append(errs, prov.validateExternalIPs())
+		return utilerrors.NewAggregate(errs)
+	default:
+		errs = append(errs, fmt.Errorf("provisioningNetwork %q is not one of %s, %s or %s", spec.ProvisioningNetwork,
+			ProvisioningNetworkManaged, ProvisioningNetworkUnmanaged, ProvisioningNetworkDisabled))
+		return utilerrors.NewAggregate(errs)
+	}
+
+	if prov.ProvisioningInterfaceRequired() && spec.ProvisioningInterface == "" && len(spec.ProvisioningMacAddresses) == 0 {
+		errs = append(errs, fmt.Errorf("provisioningInterface or provisioningMacAddresses is required when provisioningNetwork is %s", prov.NetworkMode()))
+	}
+	for _, mac := range spec.ProvisioningMacAddresses {
+		if _, err := net.ParseMAC(mac); err != nil {
+			errs = append(errs, fmt.Errorf("could not parse provisioningMacAddresses entry %q", mac))
+		}
+	}
+
+	_, cidr, err := net.ParseCIDR(spec.ProvisioningNetworkCIDR)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not parse provisioningNetworkCIDR %q", spec.ProvisioningNetworkCIDR))
+	}
+
+	ip := net.ParseIP(spec.ProvisioningIP)
+	switch {
+	case ip == nil:
+		errs = append(errs, fmt.Errorf("could not parse provisioningIP %q", spec.ProvisioningIP))
+	case cidr != nil && isIPv4(ip) != isIPv4(cidr.IP):
+		errs = append(errs, fmt.Errorf("provisioningIP %q is not of the same address family as the provisioningNetworkCIDR %q", spec.ProvisioningIP, spec.ProvisioningNetworkCIDR))
+	case cidr != nil && !cidr.Contains(ip):
+		errs = append(errs, fmt.Errorf("provisioningIP %q is not in the range defined by the provisioningNetworkCIDR %q", spec.ProvisioningIP, spec.ProvisioningNetworkCIDR))
+	}
+
+	// The DHCP range is only used when the DHCP server runs within
+	// the metal3 cluster, and an empty range selects the default.
+	if prov.DHCPServer() != DHCPServerInternal || spec.ProvisioningDHCPRange == "" {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	// dnsmasq refuses DHCPv6 ranges in networks with a prefix shorter
+	// than 64 bits.
+	if cidr != nil && !isIPv4(cidr.IP) {
+		if ones, _ := cidr.Mask.Size(); ones < minDHCPv6PrefixLength {
+			errs = append(errs, fmt.Errorf("provisioningNetworkCIDR %q should have a prefix length of at least %d to serve DHCPv6", spec.ProvisioningNetworkCIDR, minDHCPv6PrefixLength))
+		}
+	}
+
+	start, end, err := parseDHCPRange(spec.ProvisioningDHCPRange)
+	if err != nil {
+		errs = append(errs, err)
+		return utilerrors.NewAggregate(errs)
+	}
+	switch {
+	case cidr == nil:
+	case isIPv4(start) != isIPv4(cidr.IP):
+		errs = append(errs, fmt.Errorf("provisioningDHCPRange %q is not of the same address family as the provisioningNetworkCIDR %q", spec.ProvisioningDHCPRange, spec.ProvisioningNetworkCIDR))
+	default:
+		for _, addr := range []net.IP{start, end} {
+			if !cidr.Contains(addr) {
+				errs = append(errs, fmt.Errorf("provisioningDHCPRange address %q is not part of the provisioningNetworkCIDR %q", addr, spec.ProvisioningNetworkCIDR))
+			}
+		}
+	}
+	if ip != nil && ipInRange(ip, start, end) {
+		errs = append(errs, fmt.Errorf("provisioningIP %q value should be outside of the provisioningDHCPRange %q", spec.ProvisioningIP, spec.ProvisioningDHCPRange))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateExternalIPs checks the addresses the provisioning services
+// listen on when the provisioning network is disabled.
+func (prov *Provisioning) validateExternalIPs() error {
+	var errs []error
+
+	if prov.Spec.ExternalIronicIP == "" {
+		errs = append(errs, fmt.Errorf("externalIronicIP is required when provisioningNetwork is %s", ProvisioningNetworkDisabled))
+	} else if net.ParseIP(prov.Spec.ExternalIronicIP) == nil {
+		errs = append(errs, fmt.Errorf("could not parse externalIronicIP %q", prov.Spec.ExternalIronicIP))
+	}
+	if prov.Spec.ExternalHTTPIP != "" && net.ParseIP(prov.Spec.ExternalHTTPIP) == nil {
+		errs = append(errs, fmt.Errorf("could not parse externalHTTPIP %q", prov.Spec.ExternalHTTPIP))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateOSDownloadURL checks that ProvisioningOSDownloadURL, when set,
+// is an http or https URL naming the image file, whose query carries the
+// sha256 the image is verified against.
+func (prov *Provisioning) validateOSDownloadURL() error {
+	osURL := prov.Spec.ProvisioningOSDownloadURL
+	if osURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(osURL)
+	switch {
+	case err != nil || u.Host == "":
+		return fmt.Errorf("could not parse provisioningOSDownloadURL %q", osURL)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("provisioningOSDownloadURL %q should use http or https", osURL)
+	case path.Base(u.Path) == "/" || path.Base(u.Path) == ".":
+		return fmt.Errorf("provisioningOSDownloadURL %q should end with the name of the image file", osURL)
+	}
+	checksum := u.Query().Get(osImageChecksumParam)
+	switch {
+	case checksum == "":
+		return fmt.Errorf("provisioningOSDownloadURL %q should carry the sha256 of the image in its query", osURL)
+	case !sha256Checksum.MatchString(checksum):
+		return fmt.Errorf("provisioningOSDownloadURL %q has an invalid sha256 %q, it should be 64 hexadecimal digits", osURL, checksum)
+	}
+	return nil
+}
+
+// validateNodePlacement checks that the node selector of NodePlacement
+// is made of valid labels, and that its tolerations would be accepted by
+// the API server.
+func (prov *Provisioning) validateNodePlacement() error {
+	placement := prov.Spec.NodePlacement
+	if placement == nil {
+		return nil
+	}
+	var errs []error
+
+	for key, value := range placement.NodeSelector {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, fmt.Errorf("nodePlacement.nodeSelector key %q is invalid: %s", key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, fmt.Errorf("nodePlacement.nodeSelector value %q of %q is invalid: %s", value, key, msg))
+		}
+	}
+	for i, toleration := range placement.Tolerations {
+		if toleration.Key != "" {
+			for _, msg := range validation.IsQualifiedName(toleration.Key) {
+				errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] key %q is invalid: %s", i, toleration.Key, msg))
+			}
+		}
+		switch toleration.Operator {
+		case corev1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] without a key should use the %s operator", i, corev1.TolerationOpExists))
+			}
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] with the %s operator should not have a value", i, corev1.TolerationOpExists))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] operator %q is not one of %s or %s", i, toleration.Operator,
+				corev1.TolerationOpEqual, corev1.TolerationOpExists))
+		}
+		switch toleration.Effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, "":
+			if toleration.TolerationSeconds != nil {
+				errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] should only set tolerationSeconds with the %s effect", i, corev1.TaintEffectNoExecute))
+			}
+		case corev1.TaintEffectNoExecute:
+		default:
+			errs = append(errs, fmt.Errorf("nodePlacement.tolerations[%d] effect %q is not one of %s, %s or %s", i, toleration.Effect,
+				corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// OSImageSHA256 returns the lower case sha256 carried by the query of
+// ProvisioningOSDownloadURL, or an empty string when there is none.
+func (prov *Provisioning) OSImageSHA256() string {
+	u, err := url.Parse(prov.Spec.ProvisioningOSDownloadURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Query().Get(osImageChecksumParam))
+}
+
+// ValidateExternalIPsInMachineNetworks checks that the addresses the
+// provisioning services listen on when the provisioning network is
+// disabled are part of one of the machine networks of the cluster, and
+// are not one of the reservedIPs already used by the cluster. It needs
+// information from the cluster, so the webhook can't check it. When the
+// machine networks are not known, only the reserved IPs are checked.
+func (prov *Provisioning) ValidateExternalIPsInMachineNetworks(machineNetworks []*net.IPNet, reservedIPs []string) error {
+	if prov.NetworkMode() != ProvisioningNetworkDisabled {
+		return nil
+	}
+
+	var errs []error
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"externalIronicIP", prov.Spec.ExternalIronicIP},
+		{"externalHTTPIP", prov.Spec.ExternalHTTPIP},
+	} {
+		ip := net.ParseIP(f.value)
+		if ip == nil {
+			continue
+		}
+		if len(machineNetworks) > 0 && !networksContain(machineNetworks, ip) {
+			errs = append(errs, fmt.Errorf("%s %q is not part of any of the machine networks %v", f.name, f.value, machineNetworks))
+		}
+		for _, reserved := range reservedIPs {
+			if ip.Equal(net.ParseIP(reserved)) {
+				errs = append(errs, fmt.Errorf("%s %q is already used by the cluster", f.name, f.value))
+			}
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ValidateImmutableFields checks that none of the fields which can't
+// be changed after the installer has created the CR differ from their
+// value in old. Fields that were not set in old may still be filled in.
+// ProvisioningNetwork, ProvisioningDHCPRange, ProvisioningDHCPExternal
+// and NodePlacement are the only ones left mutable.
+func (prov *Provisioning) ValidateImmutableFields(old *Provisioning) error {
+	var errs []error
+
+	for _, c := range immutableFieldChanges(old.ImmutableFieldValues(), prov.ImmutableFieldValues()) {
+		errs = append(errs, fmt.Errorf("%s is immutable once set, cannot change %q to %q", c.name, c.old, c.new))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ChangedImmutableFields describes how the fields which can't be changed
+// once set differ from applied, as returned by ImmutableFieldValues.
+func (prov *Provisioning) ChangedImmutableFields(applied map[string]string) []string {
+	changes := []string{}
+	for _, c := range immutableFieldChanges(applied, prov.ImmutableFieldValues()) {
+		changes = append(changes, fmt.Sprintf("%s: %q -> %q", c.name, c.old, c.new))
+	}
+	return changes
+}
+
+// ImmutableFieldValues returns the value of each of the fields which
+// can't be changed once set, by field name, lists being comma separated.
+// Fields that are not set are left out.
+func (prov *Provisioning) ImmutableFieldValues() map[string]string {
+	values := map[string]string{}
+	for name, value := range map[string]string{
+		"provisioningInterface":     prov.Spec.ProvisioningInterface,
+		"provisioningMacAddresses":  strings.Join(prov.Spec.ProvisioningMacAddresses, ","),
+		"provisioningIP":            prov.Spec.ProvisioningIP,
+		"provisioningNetworkCIDR":   prov.Spec.ProvisioningNetworkCIDR,
+		"provisioningOSDownloadURL": prov.Spec.ProvisioningOSDownloadURL,
+		"externalIronicIP":          prov.Spec.ExternalIronicIP,
+		"externalHTTPIP":            prov.Spec.ExternalHTTPIP,
+	} {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// immutableFieldNames are the fields ImmutableFieldValues returns, in
+// the order of the spec.
+var immutableFieldNames = []string{
+	"provisioningInterface",
+	"provisioningMacAddresses",
+	"provisioningIP",
+	"provisioningNetworkCIDR",
+	"provisioningOSDownloadURL",
+	"externalIronicIP",
+	"externalHTTPIP",
+}
+
+type immutableFieldChange struct {
+	name     string
+	old, new string
+}
+
+// immutableFieldChanges returns the fields set in old that have another
+// value in new.
+func immutableFieldChanges(old, new map[string]string) []immutableFieldChange {
+	changes := []immutableFieldChange{}
+	for _, name := range immutableFieldNames {
+		if value, ok := old[name]; ok && new[name] != value {
+			changes = append(changes, immutableFieldChange{name: name, old: value, new: new[name]})
+		}
+	}
+	return changes
+}
+
+// parseDHCPRange splits a DHCP range of the form "start,end" into its
+// two addresses and checks that the start does not come after the end.
+func parseDHCPRange(dhcpRange string) (net.IP, net.IP, error) {
+	addrs := strings.Split(dhcpRange, ",")
+	if len(addrs) != 2 {
+		return nil, nil, fmt.Errorf("provisioningDHCPRange %q should be two comma separated IP addresses", dhcpRange)
+	}
+
+	start := net.ParseIP(strings.TrimSpace(addrs[0]))
+	end := net.ParseIP(strings.TrimSpace(addrs[1]))
+	if start == nil || end == nil {
+		return nil, nil, fmt.Errorf("could not parse provisioningDHCPRange %q", dhcpRange)
+	}
+	if isIPv4(start) != isIPv4(end) {
+		return nil, nil, fmt.Errorf("provisioningDHCPRange %q should not mix IPv4 and IPv6 addresses", dhcpRange)
+	}
+	if bytes.Compare(start.To16(), end.To16()) > 0 {
+		return nil, nil, fmt.Errorf("provisioningDHCPRange %q should start with the lower address", dhcpRange)
+	}
+	return start, end, nil
+}
+
+// networksContain reports whether ip is part of any of networks.
+func networksContain(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIPv4 reports whether ip is an IPv4 address, as opposed to an IPv6
+// one.
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
+
+// ipInRange reports whether ip lies within the inclusive range
+// delimited by start and end.
+func ipInRange(ip, start, end net.IP) bool {
+	return bytes.Compare(ip.To16(), start.To16()) >= 0 && bytes.Compare(ip.To16(), end.To16()) <= 0
+}
@@ -0,0 +1,446 @@
+package v1alpha1
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func managedSpec() ProvisioningSpec {
+	return ProvisioningSpec{
+		ProvisioningInterface:     "eth1",
+		ProvisioningIP:            "172.30.20.3",
+		ProvisioningNetworkCIDR:   "172.30.20.0/24",
+		ProvisioningDHCPRange:     "172.30.20.11, 172.30.20.101",
+		ProvisioningOSDownloadURL: "http://172.22.0.1/images/rhcos-44.81.202001171431.0-openstack.x86_64.qcow2.gz?sha256=e98f83a2b9d4043719664a2be75fe8134dc6ca1fdbde807996622f8cc7ecd234",
+		ProvisioningNetwork:       ProvisioningNetworkManaged,
+	}
+}
+
+func TestValidateBaremetalProvisioningConfig(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          func(*ProvisioningSpec)
+		expectedError string
+	}{
+		{
+			name: "ValidManaged",
+			spec: func(*ProvisioningSpec) {},
+		},
+		{
+			name: "DefaultDHCPRange",
+			spec: func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "" },
+		},
+		{
+			name: "BadCIDR",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetworkCIDR = "172.30.20.0/33"
+			},
+			expectedError: "provisioningNetworkCIDR",
+		},
+		{
+			name:          "BadIP",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningIP = "172.30.20" },
+			expectedError: "could not parse provisioningIP",
+		},
+		{
+			name:          "IPOutsideCIDR",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningIP = "172.30.30.3" },
+			expectedError: "is not in the range defined by the provisioningNetworkCIDR",
+		},
+		{
+			name:          "IPInsideDHCPRange",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningIP = "172.30.20.20" },
+			expectedError: "should be outside of the provisioningDHCPRange",
+		},
+		{
+			name:          "DHCPRangeSingleAddress",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "172.30.20.11" },
+			expectedError: "should be two comma separated IP addresses",
+		},
+		{
+			name:          "DHCPRangeBadAddress",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "172.30.20.11, 172.30.20" },
+			expectedError: "could not parse provisioningDHCPRange",
+		},
+		{
+			name:          "DHCPRangeReversed",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "172.30.20.101, 172.30.20.11" },
+			expectedError: "should start with the lower address",
+		},
+		{
+			name:          "DHCPRangeOutsideCIDR",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "172.30.20.11, 172.30.21.101" },
+			expectedError: "provisioningDHCPRange address \"172.30.21.101\" is not part of the provisioningNetworkCIDR",
+		},
+		{
+			name: "ValidIPv6",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+				s.ProvisioningDHCPRange = "fd00:1101::a, fd00:1101::ffff:ffff:ffff:fffe"
+			},
+		},
+		{
+			name: "IPv6DefaultDHCPRange",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+				s.ProvisioningDHCPRange = ""
+			},
+		},
+		{
+			name: "IPv6IPInsideDHCPRange",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::20"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+				s.ProvisioningDHCPRange = "fd00:1101::a,fd00:1101::100"
+			},
+			expectedError: "should be outside of the provisioningDHCPRange",
+		},
+		{
+			name: "IPv6DHCPRangeOutsideCIDR",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+				s.ProvisioningDHCPRange = "fd00:1101::a,fd00:1102::100"
+			},
+			expectedError: "provisioningDHCPRange address \"fd00:1102::100\" is not part of the provisioningNetworkCIDR",
+		},
+		{
+			name: "IPv6PrefixTooShortForDHCPv6",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/48"
+				s.ProvisioningDHCPRange = "fd00:1101::a,fd00:1101::100"
+			},
+			expectedError: "should have a prefix length of at least 64",
+		},
+		{
+			name: "IPv6UnmanagedShortPrefix",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkUnmanaged
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/48"
+			},
+		},
+		{
+			name:          "IPv6IPInIPv4CIDR",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningIP = "fd00:1101::3" },
+			expectedError: "provisioningIP \"fd00:1101::3\" is not of the same address family as the provisioningNetworkCIDR",
+		},
+		{
+			name: "IPv4IPInIPv6CIDR",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+				s.ProvisioningDHCPRange = "fd00:1101::a,fd00:1101::100"
+			},
+			expectedError: "provisioningIP \"172.30.20.3\" is not of the same address family as the provisioningNetworkCIDR",
+		},
+		{
+			name: "IPv4DHCPRangeInIPv6CIDR",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningIP = "fd00:1101::3"
+				s.ProvisioningNetworkCIDR = "fd00:1101::/64"
+			},
+			expectedError: "provisioningDHCPRange \"172.30.20.11, 172.30.20.101\" is not of the same address family",
+		},
+		{
+			name:          "DHCPRangeMixedFamilies",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningDHCPRange = "172.30.20.11,fd00:1101::100" },
+			expectedError: "should not mix IPv4 and IPv6 addresses",
+		},
+		{
+			name:          "MissingInterface",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningInterface = "" },
+			expectedError: "provisioningInterface or provisioningMacAddresses is required when provisioningNetwork is Managed",
+		},
+		{
+			name: "MacAddressesInsteadOfInterface",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningInterface = ""
+				s.ProvisioningMacAddresses = []string{"52:54:00:aa:bb:01", "52:54:00:AA:BB:02"}
+			},
+		},
+		{
+			name: "UnmanagedMissingInterface",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkUnmanaged
+				s.ProvisioningInterface = ""
+			},
+			expectedError: "provisioningInterface or provisioningMacAddresses is required when provisioningNetwork is Unmanaged",
+		},
+		{
+			name:          "BadMacAddress",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningMacAddresses = []string{"52:54:00:aa:bb"} },
+			expectedError: "could not parse provisioningMacAddresses entry \"52:54:00:aa:bb\"",
+		},
+		{
+			name:          "UnknownProvisioningNetwork",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningNetwork = "Bogus" },
+			expectedError: "provisioningNetwork \"Bogus\" is not one of Managed, Unmanaged or Disabled",
+		},
+		{
+			name: "UnknownProvisioningNetworkAndBadOSDownloadURL",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = "Bogus"
+				s.ProvisioningOSDownloadURL = "http://172.22.0.1/images/rhcos.qcow2.gz"
+			},
+			expectedError: "should carry the sha256 of the image in its query, provisioningNetwork \"Bogus\" is not one of",
+		},
+		{
+			name: "UnmanagedIgnoresDHCPRange",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkUnmanaged
+				s.ProvisioningDHCPRange = "bogus"
+			},
+		},
+		{
+			name: "DHCPExternalIgnoresDHCPRange",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ""
+				s.ProvisioningDHCPExternal = true
+				s.ProvisioningDHCPRange = "bogus"
+			},
+		},
+		{
+			name: "DisabledSkipsNetworkChecks",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+				s.ProvisioningNetworkCIDR = ""
+				s.ExternalIronicIP = "192.168.111.5"
+			},
+		},
+		{
+			name: "DisabledIgnoresProvisioningInterface",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+				s.ProvisioningInterface = ""
+				s.ExternalIronicIP = "fd2e:6f44:5dd8:c956::5"
+				s.ExternalHTTPIP = "fd2e:6f44:5dd8:c956::6"
+			},
+		},
+		{
+			name: "DisabledMissingExternalIronicIP",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+			},
+			expectedError: "externalIronicIP is required when provisioningNetwork is Disabled",
+		},
+		{
+			name: "DisabledBadExternalIPs",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+				s.ExternalIronicIP = "192.168.111"
+				s.ExternalHTTPIP = "bogus"
+			},
+			expectedError: "could not parse externalIronicIP \"192.168.111\", could not parse externalHTTPIP \"bogus\"",
+		},
+		{
+			name: "ManagedIgnoresExternalIPs",
+			spec: func(s *ProvisioningSpec) {
+				s.ExternalIronicIP = "bogus"
+			},
+		},
+		{
+			name: "NoOSDownloadURL",
+			spec: func(s *ProvisioningSpec) { s.ProvisioningOSDownloadURL = "" },
+		},
+		{
+			name:          "OSDownloadURLUnparsable",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningOSDownloadURL = "http://172.22.0.1:port/rhcos.qcow2.gz" },
+			expectedError: "could not parse provisioningOSDownloadURL",
+		},
+		{
+			name:          "OSDownloadURLRelative",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningOSDownloadURL = "images/rhcos.qcow2.gz" },
+			expectedError: "could not parse provisioningOSDownloadURL",
+		},
+		{
+			name: "OSDownloadURLBadScheme",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningOSDownloadURL = "ftp://172.22.0.1/rhcos.qcow2.gz?sha256=e98f83a2b9d4043719664a2be75fe8134dc6ca1fdbde807996622f8cc7ecd234"
+			},
+			expectedError: "should use http or https",
+		},
+		{
+			name: "OSDownloadURLNoFile",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningOSDownloadURL = "https://172.22.0.1/?sha256=e98f83a2b9d4043719664a2be75fe8134dc6ca1fdbde807996622f8cc7ecd234"
+			},
+			expectedError: "should end with the name of the image file",
+		},
+		{
+			name:          "OSDownloadURLNoChecksum",
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningOSDownloadURL = "http://172.22.0.1/images/rhcos.qcow2.gz" },
+			expectedError: "should carry the sha256 of the image in its query",
+		},
+		{
+			name: "OSDownloadURLBadChecksum",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningOSDownloadURL = "http://172.22.0.1/images/rhcos.qcow2.gz?sha256=e98f83a2"
+			},
+			expectedError: "has an invalid sha256 \"e98f83a2\"",
+		},
+		{
+			name: "DisabledChecksOSDownloadURL",
+			spec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+				s.ExternalIronicIP = "192.168.111.5"
+				s.ProvisioningOSDownloadURL = "http://192.168.111.1/rhcos.qcow2.gz"
+			},
+			expectedError: "should carry the sha256 of the image in its query",
+		},
+		{
+			name: "NodePlacement",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{
+					NodeSelector: map[string]string{"example.com/provisioning": ""},
+					Tolerations: []corev1.Toleration{
+						{Operator: corev1.TolerationOpExists},
+						{Key: "example.com/edge", Value: "true", Effect: corev1.TaintEffectNoExecute, TolerationSeconds: new(int64)},
+					},
+				}
+			},
+		},
+		{
+			name: "NodePlacementBadSelector",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{NodeSelector: map[string]string{"example.com/provisioning": "yes please"}}
+			},
+			expectedError: "nodePlacement.nodeSelector value \"yes please\" of \"example.com/provisioning\" is invalid",
+		},
+		{
+			name: "NodePlacementExistsWithValue",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{Tolerations: []corev1.Toleration{
+					{Key: "example.com/edge", Operator: corev1.TolerationOpExists, Value: "true"},
+				}}
+			},
+			expectedError: "nodePlacement.tolerations[0] with the Exists operator should not have a value",
+		},
+		{
+			name: "NodePlacementEqualWithoutKey",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{Tolerations: []corev1.Toleration{{Value: "true"}}}
+			},
+			expectedError: "nodePlacement.tolerations[0] without a key should use the Exists operator",
+		},
+		{
+			name: "NodePlacementBadEffect",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{Tolerations: []corev1.Toleration{
+					{Operator: corev1.TolerationOpExists, Effect: "NoScheduling"},
+				}}
+			},
+			expectedError: "nodePlacement.tolerations[0] effect \"NoScheduling\" is not one of",
+		},
+		{
+			name: "NodePlacementSecondsWithoutNoExecute",
+			spec: func(s *ProvisioningSpec) {
+				s.NodePlacement = &NodePlacement{Tolerations: []corev1.Toleration{
+					{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule, TolerationSeconds: new(int64)},
+				}}
+			},
+			expectedError: "nodePlacement.tolerations[0] should only set tolerationSeconds with the NoExecute effect",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &Provisioning{Spec: managedSpec()}
+			tc.spec(&prov.Spec)
+
+			err := prov.ValidateBaremetalProvisioningConfig()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestOSImageSHA256(t *testing.T) {
+	prov := &Provisioning{Spec: managedSpec()}
+	assert.Equal(t, "e98f83a2b9d4043719664a2be75fe8134dc6ca1fdbde807996622f8cc7ecd234", prov.OSImageSHA256())
+
+	prov.Spec.ProvisioningOSDownloadURL = "http://172.22.0.1/images/rhcos.qcow2.gz?sha256=E98F83A2B9D4043719664A2BE75FE8134DC6CA1FDBDE807996622F8CC7ECD234"
+	assert.Equal(t, "e98f83a2b9d4043719664a2be75fe8134dc6ca1fdbde807996622f8cc7ecd234", prov.OSImageSHA256())
+
+	prov.Spec.ProvisioningOSDownloadURL = ""
+	assert.Equal(t, "", prov.OSImageSHA256())
+}
+
+func TestValidateExternalIPsInMachineNetworks(t *testing.T) {
+	machineNetworks := []*net.IPNet{
+		{IP: net.ParseIP("192.168.111.0").To4(), Mask: net.CIDRMask(24, 32)},
+		{IP: net.ParseIP("fd2e:6f44:5dd8:c956::"), Mask: net.CIDRMask(64, 128)},
+	}
+	reservedIPs := []string{"192.168.111.5", "192.168.111.4"}
+
+	testCases := []struct {
+		name          string
+		spec          ProvisioningSpec
+		expectedError string
+	}{
+		{
+			name: "IPv4",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+				ExternalHTTPIP:      "192.168.111.11",
+			},
+		},
+		{
+			name: "IPv6",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "fd2e:6f44:5dd8:c956::10",
+				ExternalHTTPIP:      "fd2e:6f44:5dd8:c956::10",
+			},
+		},
+		{
+			name: "OutsideMachineNetworks",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+				ExternalHTTPIP:      "172.22.0.3",
+			},
+			expectedError: "externalHTTPIP \"172.22.0.3\" is not part of any of the machine networks",
+		},
+		{
+			name: "ReservedIP",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.5",
+				ExternalHTTPIP:      "192.168.111.11",
+			},
+			expectedError: "externalIronicIP \"192.168.111.5\" is already used by the cluster",
+		},
+		{
+			name: "Managed",
+			spec: ProvisioningSpec{
+				ProvisioningNetwork: ProvisioningNetworkManaged,
+				ExternalIronicIP:    "172.22.0.3",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &Provisioning{Spec: tc.spec}
+
+			err := prov.ValidateExternalIPsInMachineNetworks(machineNetworks, reservedIPs)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var provisioninglog = logf.Log.WithName("provisioning-resource")
+
+// RejectNonSingleton makes the validating webhook refuse Provisioning
+// objects not named ProvisioningSingletonName. When false they are
+// admitted, and the operator marks them as ignored instead.
+var RejectNonSingleton = true
+
+// SetupWebhookWithManager registers the Provisioning webhooks with
+// the manager's webhook server.
+func (prov *Provisioning) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(prov).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-metal3-io-v1alpha1-provisioning,mutating=true,failurePolicy=fail,groups=metal3.io,resources=provisionings,verbs=create;update,versions=v1alpha1,name=mprovisioning.kb.io
+
+var _ webhook.Defaulter = &Provisioning{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (prov *Provisioning) Default() {
+	provisioninglog.Info("default", "name", prov.Name)
+	prov.SetDefaults()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metal3-io-v1alpha1-provisioning,mutating=false,failurePolicy=fail,groups=metal3.io,resources=provisionings,versions=v1alpha1,name=vprovisioning.kb.io
+
+var _ webhook.Validator = &Provisioning{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateCreate() error {
+	provisioninglog.Info("validate create", "name", prov.Name)
+	return prov.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateUpdate(old runtime.Object) error {
+	provisioninglog.Info("validate update", "name", prov.Name)
+	if err := prov.validate(); err != nil {
+		return err
+	}
+
+	oldProv, ok := old.(*Provisioning)
+	if !ok {
+		return fmt.Errorf("expected a Provisioning object but got %T", old)
+	}
+	if err := prov.ValidateImmutableFields(oldProv); err != nil {
+		reason := prov.Annotations[ImmutableFieldsOverrideAnnotation]
+		if reason == "" {
+			return fmt.Errorf("%v (set the %q annotation with the reason for the change to override)", err, ImmutableFieldsOverrideAnnotation)
+		}
+		provisioninglog.Info("allowing change to immutable fields", "name", prov.Name, "reason", reason, "changes", err.Error())
+	}
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateDelete() error {
+	provisioninglog.Info("validate delete", "name", prov.Name)
+	return nil
+}
+
+// validate runs the checks shared by create and update.
+func (prov *Provisioning) validate() error {
+	// provisioning.metal3.io is a singleton
+	if RejectNonSingleton && prov.Name != ProvisioningSingletonName {
+		return fmt.Errorf("provisioning object is a singleton and must be named %q", ProvisioningSingletonName)
+	}
+	return prov.ValidateBaremetalProvisioningConfig()
+}
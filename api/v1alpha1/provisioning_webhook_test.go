@@ -0,0 +1,189 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateCreate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		crName        string
+		spec          func(*ProvisioningSpec)
+		expectedError string
+	}{
+		{
+			name:   "Valid",
+			crName: ProvisioningSingletonName,
+			spec:   func(*ProvisioningSpec) {},
+		},
+		{
+			name:          "WrongName",
+			crName:        "provisioning-sample",
+			spec:          func(*ProvisioningSpec) {},
+			expectedError: "must be named \"provisioning-configuration\"",
+		},
+		{
+			name:          "UnknownProvisioningNetwork",
+			crName:        ProvisioningSingletonName,
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningNetwork = "managed" },
+			expectedError: "provisioningNetwork \"managed\" is not one of",
+		},
+		{
+			name:          "MalformedCIDR",
+			crName:        ProvisioningSingletonName,
+			spec:          func(s *ProvisioningSpec) { s.ProvisioningNetworkCIDR = "172.30.20.0" },
+			expectedError: "could not parse provisioningNetworkCIDR",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &Provisioning{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.crName},
+				Spec:       managedSpec(),
+			}
+			tc.spec(&prov.Spec)
+
+			err := prov.ValidateCreate()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+
+			// Updates are subject to the same checks
+			err = prov.ValidateUpdate(prov.DeepCopy())
+			assert.Equal(t, tc.expectedError == "", err == nil)
+		})
+	}
+}
+
+func TestValidateCreateNonSingletonAllowed(t *testing.T) {
+	RejectNonSingleton = false
+	defer func() { RejectNonSingleton = true }()
+
+	prov := &Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: "provisioning-sample"},
+		Spec:       managedSpec(),
+	}
+	assert.NoError(t, prov.ValidateCreate())
+
+	// The spec is still validated
+	prov.Spec.ProvisioningNetworkCIDR = "172.30.20.0"
+	assert.Error(t, prov.ValidateCreate())
+}
+
+func TestValidateDelete(t *testing.T) {
+	prov := &Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: "provisioning-sample"},
+	}
+	assert.NoError(t, prov.ValidateDelete())
+}
+
+func TestValidateUpdateImmutableFields(t *testing.T) {
+	testCases := []struct {
+		name          string
+		oldSpec       func(*ProvisioningSpec)
+		update        func(*Provisioning)
+		expectedError string
+	}{
+		{
+			name:   "DHCPRangeIsMutable",
+			update: func(p *Provisioning) { p.Spec.ProvisioningDHCPRange = "172.30.20.20, 172.30.20.50" },
+		},
+		{
+			name:          "ChangeInterface",
+			update:        func(p *Provisioning) { p.Spec.ProvisioningInterface = "ens3" },
+			expectedError: "provisioningInterface is immutable once set",
+		},
+		{
+			name:          "ChangeIP",
+			update:        func(p *Provisioning) { p.Spec.ProvisioningIP = "172.30.20.4" },
+			expectedError: "provisioningIP is immutable once set",
+		},
+		{
+			name: "ChangeCIDR",
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningNetworkCIDR = "172.30.0.0/16"
+			},
+			expectedError: "provisioningNetworkCIDR is immutable once set",
+		},
+		{
+			name: "ChangeOSDownloadURL",
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningOSDownloadURL = "http://example.com/rhcos.qcow2.gz?sha256=" + strings.Repeat("0", 64)
+			},
+			expectedError: "provisioningOSDownloadURL is immutable once set",
+		},
+		{
+			name:   "NetworkIsMutable",
+			update: func(p *Provisioning) { p.Spec.ProvisioningNetwork = ProvisioningNetworkUnmanaged },
+		},
+		{
+			name:    "ChangeMacAddresses",
+			oldSpec: func(s *ProvisioningSpec) { s.ProvisioningMacAddresses = []string{"52:54:00:aa:bb:01"} },
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningMacAddresses = []string{"52:54:00:aa:bb:02"}
+			},
+			expectedError: "provisioningMacAddresses is immutable once set",
+		},
+		{
+			name: "ChangeExternalIPs",
+			oldSpec: func(s *ProvisioningSpec) {
+				s.ProvisioningNetwork = ProvisioningNetworkDisabled
+				s.ExternalIronicIP = "192.168.111.5"
+				s.ExternalHTTPIP = "192.168.111.6"
+			},
+			update: func(p *Provisioning) {
+				p.Spec.ExternalIronicIP = "192.168.111.7"
+				p.Spec.ExternalHTTPIP = "192.168.111.8"
+			},
+			expectedError: "externalIronicIP is immutable once set, cannot change \"192.168.111.5\" to \"192.168.111.7\", externalHTTPIP is immutable once set",
+		},
+		{
+			name:    "SetUnsetField",
+			oldSpec: func(s *ProvisioningSpec) { s.ProvisioningOSDownloadURL = "" },
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningOSDownloadURL = "http://example.com/rhcos.qcow2.gz?sha256=" + strings.Repeat("0", 64)
+			},
+		},
+		{
+			name: "OverrideAnnotation",
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningInterface = "ens3"
+				p.Annotations = map[string]string{ImmutableFieldsOverrideAnnotation: "NIC replaced on all masters"}
+			},
+		},
+		{
+			name: "EmptyOverrideAnnotation",
+			update: func(p *Provisioning) {
+				p.Spec.ProvisioningInterface = "ens3"
+				p.Annotations = map[string]string{ImmutableFieldsOverrideAnnotation: ""}
+			},
+			expectedError: ImmutableFieldsOverrideAnnotation,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			old := &Provisioning{
+				ObjectMeta: metav1.ObjectMeta{Name: ProvisioningSingletonName},
+				Spec:       managedSpec(),
+			}
+			if tc.oldSpec != nil {
+				tc.oldSpec(&old.Spec)
+			}
+			prov := old.DeepCopy()
+			tc.update(prov)
+
+			err := prov.ValidateUpdate(old)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// dhcpExternalAnnotation preserves the deprecated v1alpha1
+	// provisioningDHCPExternal field, which has no v1beta1 equivalent.
+	dhcpExternalAnnotation = "metal3.io/v1alpha1-provisioning-dhcp-external"
+
+	// dhcpRangeAnnotation preserves the v1alpha1 provisioningDHCPRange
+	// string when it can't be reproduced from the structured dhcpRange.
+	dhcpRangeAnnotation = "metal3.io/v1alpha1-provisioning-dhcp-range"
+)
+
+var _ conversion.Convertible = &Provisioning{}
+
+// ConvertTo converts this Provisioning to the Hub version (v1alpha1).
+func (src *Provisioning) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.Provisioning)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub %T", dstRaw)
+	}
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	annotations := dst.GetAnnotations()
+
+	dst.Spec = v1alpha1.ProvisioningSpec{
+		ProvisioningInterface:     src.Spec.ProvisioningInterface,
+		ProvisioningMacAddresses:  append([]string(nil), src.Spec.ProvisioningMacAddresses...),
+		ProvisioningIP:            src.Spec.ProvisioningIP,
+		ProvisioningNetworkCIDR:   src.Spec.ProvisioningNetworkCIDR,
+		ProvisioningOSDownloadURL: src.Spec.ProvisioningOSDownloadURL,
+		ProvisioningNetwork:       v1alpha1.ProvisioningNetwork(src.Spec.ProvisioningNetwork),
+		ExternalIronicIP:          src.Spec.ExternalIronicIP,
+		ExternalHTTPIP:            src.Spec.ExternalHTTPIP,
+		ProvisioningDHCPExternal:  annotations[dhcpExternalAnnotation] == "true",
+	}
+	if placement := src.Spec.NodePlacement.DeepCopy(); placement != nil {
+		dst.Spec.NodePlacement = &v1alpha1.NodePlacement{
+			NodeSelector: placement.NodeSelector,
+			Tolerations:  placement.Tolerations,
+		}
+	}
+
+	// Prefer the original string as long as it still describes the
+	// same range, so that formatting survives a round trip.
+	original, hasOriginal := annotations[dhcpRangeAnnotation]
+	switch {
+	case src.Spec.DHCPRange == nil && hasOriginal:
+		dst.Spec.ProvisioningDHCPRange = original
+	case src.Spec.DHCPRange != nil:
+		dst.Spec.ProvisioningDHCPRange = formatDHCPRange(src.Spec.DHCPRange)
+		if hasOriginal {
+			if parsed := parseDHCPRange(original); parsed != nil && *parsed == *src.Spec.DHCPRange {
+				dst.Spec.ProvisioningDHCPRange = original
+			}
+		}
+	}
+
+	delete(annotations, dhcpExternalAnnotation)
+	delete(annotations, dhcpRangeAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	dst.SetAnnotations(annotations)
+
+	dst.Status = v1alpha1.ProvisioningStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+	}
+	if src.Status.EffectiveConfig != nil {
+		dst.Status.EffectiveConfig = &v1alpha1.EffectiveProvisioningConfig{
+			ProvisioningNetwork: v1alpha1.ProvisioningNetwork(src.Status.EffectiveConfig.ProvisioningNetwork),
+		}
+		if src.Status.EffectiveConfig.DHCPRange != nil {
+			dst.Status.EffectiveConfig.ProvisioningDHCPRange = formatDHCPRange(src.Status.EffectiveConfig.DHCPRange)
+		}
+	}
+	if rotation := src.Status.CredentialsRotation; rotation != nil {
+		dst.Status.CredentialsRotation = &v1alpha1.CredentialsRotationStatus{
+			Request:          rotation.Request,
+			Phase:            v1alpha1.CredentialsRotationPhase(rotation.Phase),
+			LastRotationTime: rotation.LastRotationTime.DeepCopy(),
+		}
+	}
+	if tls := src.Status.TLS; tls != nil {
+		dst.Status.TLS = &v1alpha1.TLSStatus{
+			CAExpiry:          tls.CAExpiry.DeepCopy(),
+			CertificateExpiry: tls.CertificateExpiry.DeepCopy(),
+		}
+	}
+	if immutable := src.Status.ImmutableFields; immutable != nil {
+		dst.Status.ImmutableFields = &v1alpha1.ImmutableFieldsStatus{Applied: immutable.Applied}
+		if override := immutable.LastOverride; override != nil {
+			dst.Status.ImmutableFields.LastOverride = &v1alpha1.ImmutableFieldsOverride{
+				Reason:  override.Reason,
+				Time:    override.Time,
+				Changes: override.Changes,
+			}
+		}
+	}
+	if endpoints := src.Status.Endpoints; endpoints != nil {
+		dst.Status.Endpoints = &v1alpha1.EndpointsStatus{
+			Ironic:    endpoints.Ironic,
+			Inspector: endpoints.Inspector,
+		}
+	}
+	if cache := src.Status.ImageCache; cache != nil {
+		dst.Status.ImageCache = &v1alpha1.ImageCacheStatus{
+			Phase:           v1alpha1.ImageCachePhase(cache.Phase),
+			URL:             cache.URL,
+			CachedNodes:     cache.CachedNodes,
+			DesiredNodes:    cache.DesiredNodes,
+			DownloadedBytes: cache.DownloadedBytes,
+			TotalBytes:      cache.TotalBytes,
+			SHA256:          cache.SHA256,
+			Message:         cache.Message,
+		}
+	}
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, *c.DeepCopy())
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *Provisioning) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.Provisioning)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub %T", srcRaw)
+	}
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	annotations := dst.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	dst.Spec = ProvisioningSpec{
+		ProvisioningInterface:     src.Spec.ProvisioningInterface,
+		ProvisioningMacAddresses:  append([]string(nil), src.Spec.ProvisioningMacAddresses...),
+		ProvisioningIP:            src.Spec.ProvisioningIP,
+		ProvisioningNetworkCIDR:   src.Spec.ProvisioningNetworkCIDR,
+		ProvisioningOSDownloadURL: src.Spec.ProvisioningOSDownloadURL,
+		ProvisioningNetwork:       ProvisioningNetwork(src.Spec.ProvisioningNetwork),
+		ExternalIronicIP:          src.Spec.ExternalIronicIP,
+		ExternalHTTPIP:            src.Spec.ExternalHTTPIP,
+	}
+	if placement := src.Spec.NodePlacement.DeepCopy(); placement != nil {
+		dst.Spec.NodePlacement = &NodePlacement{
+			NodeSelector: placement.NodeSelector,
+			Tolerations:  placement.Tolerations,
+		}
+	}
+
+	if src.Spec.ProvisioningDHCPExternal {
+		annotations[dhcpExternalAnnotation] = "true"
+	}
+
+	if src.Spec.ProvisioningDHCPRange != "" {
+		dst.Spec.DHCPRange = parseDHCPRange(src.Spec.ProvisioningDHCPRange)
+		if dst.Spec.DHCPRange == nil || formatDHCPRange(dst.Spec.DHCPRange) != src.Spec.ProvisioningDHCPRange {
+			annotations[dhcpRangeAnnotation] = src.Spec.ProvisioningDHCPRange
+		}
+	}
+
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	dst.SetAnnotations(annotations)
+
+	// The operator always writes the effective DHCP range in the
+	// canonical "start,end" form, so it needs no annotation.
+	dst.Status = ProvisioningStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+	}
+	if src.Status.EffectiveConfig != nil {
+		dst.Status.EffectiveConfig = &EffectiveProvisioningConfig{
+			ProvisioningNetwork: ProvisioningNetwork(src.Status.EffectiveConfig.ProvisioningNetwork),
+		}
+		if src.Status.EffectiveConfig.ProvisioningDHCPRange != "" {
+			dst.Status.EffectiveConfig.DHCPRange = parseDHCPRange(src.Status.EffectiveConfig.ProvisioningDHCPRange)
+		}
+	}
+	if rotation := src.Status.CredentialsRotation; rotation != nil {
+		dst.Status.CredentialsRotation = &CredentialsRotationStatus{
+			Request:          rotation.Request,
+			Phase:            CredentialsRotationPhase(rotation.Phase),
+			LastRotationTime: rotation.LastRotationTime.DeepCopy(),
+		}
+	}
+	if tls := src.Status.TLS; tls != nil {
+		dst.Status.TLS = &TLSStatus{
+			CAExpiry:          tls.CAExpiry.DeepCopy(),
+			CertificateExpiry: tls.CertificateExpiry.DeepCopy(),
+		}
+	}
+	if immutable := src.Status.ImmutableFields; immutable != nil {
+		dst.Status.ImmutableFields = &ImmutableFieldsStatus{Applied: immutable.Applied}
+		if override := immutable.LastOverride; override != nil {
+			dst.Status.ImmutableFields.LastOverride = &ImmutableFieldsOverride{
+				Reason:  override.Reason,
+				Time:    override.Time,
+				Changes: override.Changes,
+			}
+		}
+	}
+	if endpoints := src.Status.Endpoints; endpoints != nil {
+		dst.Status.Endpoints = &EndpointsStatus{
+			Ironic:    endpoints.Ironic,
+			Inspector: endpoints.Inspector,
+		}
+	}
+	if cache := src.Status.ImageCache; cache != nil {
+		dst.Status.ImageCache = &ImageCacheStatus{
+			Phase:           ImageCachePhase(cache.Phase),
+			URL:             cache.URL,
+			CachedNodes:     cache.CachedNodes,
+			DesiredNodes:    cache.DesiredNodes,
+			DownloadedBytes: cache.DownloadedBytes,
+			TotalBytes:      cache.TotalBytes,
+			SHA256:          cache.SHA256,
+			Message:         cache.Message,
+		}
+	}
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, *c.DeepCopy())
+	}
+	return nil
+}
+
+// parseDHCPRange splits a v1alpha1 "start,end" DHCP range, returning
+// nil when it does not consist of two values.
+func parseDHCPRange(dhcpRange string) *DHCPRange {
+	addrs := strings.Split(dhcpRange, ",")
+	if len(addrs) != 2 {
+		return nil
+	}
+	return &DHCPRange{
+		Start: strings.TrimSpace(addrs[0]),
+		End:   strings.TrimSpace(addrs[1]),
+	}
+}
+
+// formatDHCPRange returns the v1alpha1 representation of a DHCP range.
+func formatDHCPRange(dhcpRange *DHCPRange) string {
+	return fmt.Sprintf("%s,%s", dhcpRange.Start, dhcpRange.End)
+}
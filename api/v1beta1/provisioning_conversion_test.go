@@ -0,0 +1,219 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func hubProvisioning(spec v1alpha1.ProvisioningSpec) *v1alpha1.Provisioning {
+	return &v1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        v1alpha1.ProvisioningSingletonName,
+			Annotations: map[string]string{"example.com/kept": "yes"},
+		},
+		Spec: spec,
+		Status: v1alpha1.ProvisioningStatus{
+			ObservedGeneration: 3,
+			Conditions: []metav1.Condition{
+				{Type: v1alpha1.ConditionValid, Status: metav1.ConditionTrue, Reason: "ValidationSucceeded"},
+			},
+		},
+	}
+}
+
+func TestConvertFromHub(t *testing.T) {
+	testCases := []struct {
+		name              string
+		spec              v1alpha1.ProvisioningSpec
+		expectedDHCPRange *DHCPRange
+		expectedNetwork   ProvisioningNetwork
+	}{
+		{
+			name: "CanonicalDHCPRange",
+			spec: v1alpha1.ProvisioningSpec{
+				ProvisioningInterface:     "eth1",
+				ProvisioningMacAddresses:  []string{"52:54:00:aa:bb:01"},
+				ProvisioningIP:            "172.30.20.3",
+				ProvisioningNetworkCIDR:   "172.30.20.0/24",
+				ProvisioningDHCPRange:     "172.30.20.10,172.30.20.100",
+				ProvisioningOSDownloadURL: "http://example.com/rhcos.qcow2.gz",
+				ProvisioningNetwork:       v1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedDHCPRange: &DHCPRange{Start: "172.30.20.10", End: "172.30.20.100"},
+			expectedNetwork:   ProvisioningNetworkManaged,
+		},
+		{
+			name: "DHCPRangeWithSpaces",
+			spec: v1alpha1.ProvisioningSpec{
+				ProvisioningDHCPRange: "172.30.20.11, 172.30.20.101",
+				ProvisioningNetwork:   v1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedDHCPRange: &DHCPRange{Start: "172.30.20.11", End: "172.30.20.101"},
+			expectedNetwork:   ProvisioningNetworkManaged,
+		},
+		{
+			name: "MalformedDHCPRange",
+			spec: v1alpha1.ProvisioningSpec{
+				ProvisioningDHCPRange: "172.30.20.11",
+			},
+		},
+		{
+			name: "DHCPExternal",
+			spec: v1alpha1.ProvisioningSpec{
+				ProvisioningDHCPExternal: true,
+				ProvisioningNetwork:      v1alpha1.ProvisioningNetworkUnmanaged,
+			},
+			expectedNetwork: ProvisioningNetworkUnmanaged,
+		},
+		{
+			name: "NodePlacement",
+			spec: v1alpha1.ProvisioningSpec{
+				NodePlacement: &v1alpha1.NodePlacement{
+					NodeSelector: map[string]string{"example.com/provisioning": "true"},
+					Tolerations: []corev1.Toleration{
+						{Key: "example.com/edge", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+					},
+				},
+			},
+		},
+		{
+			name: "Empty",
+			spec: v1alpha1.ProvisioningSpec{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := hubProvisioning(tc.spec)
+
+			prov := &Provisioning{}
+			assert.NoError(t, prov.ConvertFrom(hub.DeepCopy()))
+			assert.Equal(t, tc.expectedDHCPRange, prov.Spec.DHCPRange)
+			assert.Equal(t, tc.expectedNetwork, prov.Spec.ProvisioningNetwork)
+			assert.Equal(t, hub.Spec.ProvisioningIP, prov.Spec.ProvisioningIP)
+			assert.Equal(t, hub.Spec.ProvisioningMacAddresses, prov.Spec.ProvisioningMacAddresses)
+			if hub.Spec.NodePlacement != nil && assert.NotNil(t, prov.Spec.NodePlacement) {
+				assert.Equal(t, hub.Spec.NodePlacement.NodeSelector, prov.Spec.NodePlacement.NodeSelector)
+				assert.Equal(t, hub.Spec.NodePlacement.Tolerations, prov.Spec.NodePlacement.Tolerations)
+			}
+			assert.Equal(t, hub.Status.ObservedGeneration, prov.Status.ObservedGeneration)
+			assert.Equal(t, hub.Status.Conditions, prov.Status.Conditions)
+
+			// Converting back must not lose anything
+			roundTrip := &v1alpha1.Provisioning{}
+			assert.NoError(t, prov.ConvertTo(roundTrip))
+			assert.Equal(t, hub, roundTrip)
+		})
+	}
+}
+
+func TestConvertToHub(t *testing.T) {
+	prov := &Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: v1alpha1.ProvisioningSingletonName,
+		},
+		Spec: ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+			DHCPRange:               &DHCPRange{Start: "172.30.20.10", End: "172.30.20.100"},
+			ProvisioningNetwork:     ProvisioningNetworkManaged,
+		},
+	}
+
+	hub := &v1alpha1.Provisioning{}
+	assert.NoError(t, prov.ConvertTo(hub))
+	assert.Equal(t, "172.30.20.10,172.30.20.100", hub.Spec.ProvisioningDHCPRange)
+	assert.False(t, hub.Spec.ProvisioningDHCPExternal)
+	assert.Nil(t, hub.Annotations)
+
+	roundTrip := &Provisioning{}
+	assert.NoError(t, roundTrip.ConvertFrom(hub))
+	assert.Equal(t, prov, roundTrip)
+}
+
+func TestConvertEffectiveConfig(t *testing.T) {
+	hub := hubProvisioning(v1alpha1.ProvisioningSpec{})
+	hub.Status.EffectiveConfig = &v1alpha1.EffectiveProvisioningConfig{
+		ProvisioningNetwork:   v1alpha1.ProvisioningNetworkManaged,
+		ProvisioningDHCPRange: "172.30.20.10,172.30.20.100",
+	}
+
+	prov := &Provisioning{}
+	assert.NoError(t, prov.ConvertFrom(hub.DeepCopy()))
+	assert.Equal(t, &EffectiveProvisioningConfig{
+		ProvisioningNetwork: ProvisioningNetworkManaged,
+		DHCPRange:           &DHCPRange{Start: "172.30.20.10", End: "172.30.20.100"},
+	}, prov.Status.EffectiveConfig)
+
+	roundTrip := &v1alpha1.Provisioning{}
+	assert.NoError(t, prov.ConvertTo(roundTrip))
+	assert.Equal(t, hub, roundTrip)
+}
+
+func TestConvertEndpoints(t *testing.T) {
+	hub := hubProvisioning(v1alpha1.ProvisioningSpec{})
+	hub.Status.Endpoints = &v1alpha1.EndpointsStatus{
+		Ironic:    "https://metal3-ironic.openshift-machine-api.svc:6385/v1/",
+		Inspector: "https://metal3-ironic-inspector.openshift-machine-api.svc:5050/v1/",
+	}
+
+	prov := &Provisioning{}
+	assert.NoError(t, prov.ConvertFrom(hub.DeepCopy()))
+	assert.Equal(t, &EndpointsStatus{
+		Ironic:    "https://metal3-ironic.openshift-machine-api.svc:6385/v1/",
+		Inspector: "https://metal3-ironic-inspector.openshift-machine-api.svc:5050/v1/",
+	}, prov.Status.Endpoints)
+
+	roundTrip := &v1alpha1.Provisioning{}
+	assert.NoError(t, prov.ConvertTo(roundTrip))
+	assert.Equal(t, hub, roundTrip)
+}
+
+func TestConvertImmutableFields(t *testing.T) {
+	hub := hubProvisioning(v1alpha1.ProvisioningSpec{})
+	hub.Status.ImmutableFields = &v1alpha1.ImmutableFieldsStatus{
+		Applied: map[string]string{"provisioningInterface": "ens3"},
+		LastOverride: &v1alpha1.ImmutableFieldsOverride{
+			Reason:  "NIC replaced on all masters",
+			Time:    metav1.NewTime(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)),
+			Changes: []string{`provisioningInterface: "eth1" -> "ens3"`},
+		},
+	}
+
+	prov := &Provisioning{}
+	assert.NoError(t, prov.ConvertFrom(hub.DeepCopy()))
+	assert.Equal(t, &ImmutableFieldsStatus{
+		Applied: map[string]string{"provisioningInterface": "ens3"},
+		LastOverride: &ImmutableFieldsOverride{
+			Reason:  "NIC replaced on all masters",
+			Time:    metav1.NewTime(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)),
+			Changes: []string{`provisioningInterface: "eth1" -> "ens3"`},
+		},
+	}, prov.Status.ImmutableFields)
+
+	roundTrip := &v1alpha1.Provisioning{}
+	assert.NoError(t, prov.ConvertTo(roundTrip))
+	assert.Equal(t, hub, roundTrip)
+}
+
+func TestConvertToHubChangedDHCPRange(t *testing.T) {
+	// A v1alpha1 object with a non canonical range is edited through
+	// v1beta1, the stale original must not win over the new range.
+	hub := hubProvisioning(v1alpha1.ProvisioningSpec{
+		ProvisioningDHCPRange: "172.30.20.11, 172.30.20.101",
+	})
+	prov := &Provisioning{}
+	assert.NoError(t, prov.ConvertFrom(hub))
+
+	prov.Spec.DHCPRange = &DHCPRange{Start: "172.30.20.20", End: "172.30.20.50"}
+	updated := &v1alpha1.Provisioning{}
+	assert.NoError(t, prov.ConvertTo(updated))
+	assert.Equal(t, "172.30.20.20,172.30.20.50", updated.Spec.ProvisioningDHCPRange)
+	assert.NotContains(t, updated.Annotations, dhcpRangeAnnotation)
+}
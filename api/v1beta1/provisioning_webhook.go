@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// log is for logging in this package.
+var provisioninglog = logf.Log.WithName("provisioning-resource")
+
+// SetupWebhookWithManager registers the Provisioning webhooks with
+// the manager's webhook server. Defaulting and validation convert to
+// v1alpha1 so both versions are subject to exactly the same rules.
+func (prov *Provisioning) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(prov).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-metal3-io-v1beta1-provisioning,mutating=true,failurePolicy=fail,groups=metal3.io,resources=provisionings,verbs=create;update,versions=v1beta1,name=mprovisioning.v1beta1.kb.io
+
+var _ webhook.Defaulter = &Provisioning{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (prov *Provisioning) Default() {
+	provisioninglog.Info("default", "name", prov.Name)
+
+	hub := &v1alpha1.Provisioning{}
+	if err := prov.ConvertTo(hub); err != nil {
+		provisioninglog.Error(err, "unable to default", "name", prov.Name)
+		return
+	}
+	hub.SetDefaults()
+	if err := prov.ConvertFrom(hub); err != nil {
+		provisioninglog.Error(err, "unable to default", "name", prov.Name)
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metal3-io-v1beta1-provisioning,mutating=false,failurePolicy=fail,groups=metal3.io,resources=provisionings,versions=v1beta1,name=vprovisioning.v1beta1.kb.io
+
+var _ webhook.Validator = &Provisioning{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateCreate() error {
+	hub := &v1alpha1.Provisioning{}
+	if err := prov.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateCreate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateUpdate(old runtime.Object) error {
+	oldProv, ok := old.(*Provisioning)
+	if !ok {
+		return fmt.Errorf("expected a Provisioning object but got %T", old)
+	}
+
+	hub, oldHub := &v1alpha1.Provisioning{}, &v1alpha1.Provisioning{}
+	if err := prov.ConvertTo(hub); err != nil {
+		return err
+	}
+	if err := oldProv.ConvertTo(oldHub); err != nil {
+		return err
+	}
+	return hub.ValidateUpdate(oldHub)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (prov *Provisioning) ValidateDelete() error {
+	hub := &v1alpha1.Provisioning{}
+	if err := prov.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateDelete()
+}
@@ -0,0 +1,43 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestWebhookUsesHubRules(t *testing.T) {
+	prov := &Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: v1alpha1.ProvisioningSingletonName,
+		},
+		Spec: ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+		},
+	}
+
+	prov.Default()
+	assert.Equal(t, ProvisioningNetworkManaged, prov.Spec.ProvisioningNetwork)
+	assert.Equal(t, &DHCPRange{Start: "172.30.20.10", End: "172.30.20.100"}, prov.Spec.DHCPRange)
+	assert.NoError(t, prov.ValidateCreate())
+
+	bad := prov.DeepCopy()
+	bad.Spec.DHCPRange.Start = "172.30.21.10"
+	assert.Error(t, bad.ValidateCreate())
+
+	changed := prov.DeepCopy()
+	changed.Spec.ProvisioningIP = "172.30.20.4"
+	err := changed.ValidateUpdate(prov)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "provisioningIP is immutable once set")
+	}
+
+	renamed := prov.DeepCopy()
+	renamed.Name = "provisioning-sample"
+	assert.Error(t, renamed.ValidateCreate())
+}
@@ -0,0 +1,286 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// specHashAnnotation records the hash of the content last applied to an
+// object, so that changes to the rendered content can be told apart
+// from changes made to the live object.
+const specHashAnnotation = "baremetal.openshift.io/spec-hash"
+
+// fieldOwner is the field manager of the writes of the operator
+var fieldOwner = client.FieldOwner(ComponentName)
+
+// object is a Kubernetes object the operator applies.
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// apply makes the object named like desired match it, and makes the
+// Provisioning CR its controller so it is garbage collected with it.
+// Only the fields set in desired, its labels and owner references are
+// compared with the live object, so fields defaulted by the API server
+// do not cause writes. A live object which differs from the content it
+// was last applied with was edited by hand, the edit is reverted and an
+// event recorded. live is set to the object as it is after apply.
+func (r *ProvisioningReconciler) apply(prov *metal3iov1alpha1.Provisioning, desired, live object) error {
+	ctx := context.Background()
+	kind := r.kindOf(desired)
+
+	if err := controllerutil.SetControllerReference(prov, desired, r.Scheme); err != nil {
+		return errors.Wrapf(err, "unable to set the owner of %s %s/%s", kind, desired.GetNamespace(), desired.GetName())
+	}
+	content, err := applyContent(desired)
+	if err != nil {
+		return errors.Wrapf(err, "unable to convert %s %s/%s", kind, desired.GetNamespace(), desired.GetName())
+	}
+	hash, err := contentHash(content)
+	if err != nil {
+		return errors.Wrapf(err, "unable to hash %s %s/%s", kind, desired.GetNamespace(), desired.GetName())
+	}
+	annotations := desired.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[specHashAnnotation] = hash
+	desired.SetAnnotations(annotations)
+
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: desired.GetNamespace(), Name: desired.GetName()}, live)
+	if apierrors.IsNotFound(err) {
+		if err := r.Client.Create(ctx, desired, fieldOwner); err != nil {
+			return errors.Wrapf(err, "unable to create %s %s/%s", kind, desired.GetNamespace(), desired.GetName())
+		}
+		r.Log.Info("applied", "kind", kind, "name", desired.GetName(), "operation", "created")
+		return copyObject(desired, live)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to get %s %s/%s", kind, desired.GetNamespace(), desired.GetName())
+	}
+
+	liveContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return errors.Wrapf(err, "unable to convert %s %s/%s", kind, live.GetNamespace(), live.GetName())
+	}
+	diff := diffPaths("", content, liveContent)
+	// The content was already applied, any difference is a manual edit
+	edited := live.GetAnnotations()[specHashAnnotation] == hash
+	if edited && len(diff) == 0 {
+		return nil
+	}
+
+	if _, ok := desired.(*corev1.Service); ok {
+		keepClusterIP(content, liveContent)
+	}
+	updated := mergeContent(liveContent, content, annotations)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(updated, live); err != nil {
+		return errors.Wrapf(err, "unable to convert %s %s/%s", kind, live.GetNamespace(), live.GetName())
+	}
+	if err := r.Client.Update(ctx, live, fieldOwner); err != nil {
+		return errors.Wrapf(err, "unable to update %s %s/%s", kind, live.GetNamespace(), live.GetName())
+	}
+
+	if edited {
+		r.Log.Info("reverted manual changes", "kind", kind, "name", live.GetName(), "diff", diff)
+		r.EventRecorder.Eventf(prov, corev1.EventTypeWarning, "ManualChangesReverted",
+			"reverted manual changes to %s %s/%s: %s", kind, live.GetNamespace(), live.GetName(), strings.Join(diff, ", "))
+		return nil
+	}
+	r.Log.Info("applied", "kind", kind, "name", live.GetName(), "operation", "updated", "diff", diff)
+	return nil
+}
+
+// kindOf returns the kind of obj, or its Go type when it is not known
+// to the scheme.
+func (r *ProvisioningReconciler) kindOf(obj runtime.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return fmt.Sprintf("%T", obj)
+	}
+	return gvk.Kind
+}
+
+// applyContent returns the fields of obj owned by the operator: all of
+// them but the status and the metadata other than labels and owner
+// references.
+func applyContent(obj object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(content, "status")
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	metadata := map[string]interface{}{}
+	if m, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"labels", "ownerReferences"} {
+			if v, ok := m[field]; ok {
+				metadata[field] = v
+			}
+		}
+	}
+	content["metadata"] = metadata
+	return content, nil
+}
+
+// contentHash returns a hash of content, whose JSON encoding is stable
+// as map keys are sorted.
+func contentHash(content map[string]interface{}) (string, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// mergeContent returns live with the fields of content, and the labels
+// and annotations of the object merged into its own.
+func mergeContent(live, content map[string]interface{}, annotations map[string]string) map[string]interface{} {
+	merged := runtime.DeepCopyJSON(live)
+	for field, value := range content {
+		if field != "metadata" {
+			merged[field] = runtime.DeepCopyJSONValue(value)
+		}
+	}
+
+	metadata, _ := merged["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		merged["metadata"] = metadata
+	}
+	desiredMetadata := content["metadata"].(map[string]interface{})
+	if refs, ok := desiredMetadata["ownerReferences"]; ok {
+		metadata["ownerReferences"] = runtime.DeepCopyJSONValue(refs)
+	}
+	labels, _ := desiredMetadata["labels"].(map[string]interface{})
+	metadata["labels"] = mergeStringMap(metadata["labels"], labels)
+	desiredAnnotations := map[string]interface{}{}
+	for k, v := range annotations {
+		desiredAnnotations[k] = v
+	}
+	metadata["annotations"] = mergeStringMap(metadata["annotations"], desiredAnnotations)
+	return merged
+}
+
+func mergeStringMap(existing interface{}, overrides map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	if m, ok := existing.(map[string]interface{}); ok {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// keepClusterIP copies into the content of a Service the cluster IP the
+// API server allocated to live, as it can't be changed, and the rendered
+// Service leaves it to be allocated.
+func keepClusterIP(content, live map[string]interface{}) {
+	clusterIP, found, err := unstructured.NestedString(live, "spec", "clusterIP")
+	if err != nil || !found {
+		return
+	}
+	if _, set, _ := unstructured.NestedString(content, "spec", "clusterIP"); !set {
+		// The content only has fields from a typed object, which can
+		// always be set
+		_ = unstructured.SetNestedField(content, clusterIP, "spec", "clusterIP")
+	}
+}
+
+// diffPaths returns the paths of the fields set in desired which live
+// does not have the same value for. Fields only set in live, such as
+// defaults, are ignored. Lists must have the same length.
+func diffPaths(path string, desired, live interface{}) []string {
+	switch d := desired.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			if len(d) == 0 && live == nil {
+				return nil
+			}
+			return []string{path}
+		}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var paths []string
+		for _, k := range keys {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			paths = append(paths, diffPaths(fieldPath, d[k], l[k])...)
+		}
+		return paths
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			if len(d) == 0 && live == nil {
+				return nil
+			}
+			return []string{path}
+		}
+		var paths []string
+		for i := range d {
+			paths = append(paths, diffPaths(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return paths
+	default:
+		if !reflect.DeepEqual(desired, live) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// copyObject sets dst to a copy of src, which has the same type.
+func copyObject(src, dst object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(src)
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, dst)
+}
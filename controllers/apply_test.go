@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+func TestApply(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+			UID:  "2f0f8bdb-b5a4-4f0e-a9a7-5b7a9c1b0f11",
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	images, err := provisioning.LoadImages("testdata/images.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), prov)
+	recorder := reconciler.EventRecorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}
+
+	apply := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		assert.NoError(t, reconciler.apply(prov, provisioning.NewMetal3Deployment(prov, images, ComponentNamespace), deployment))
+		return deployment
+	}
+
+	created := apply()
+	assert.NotEmpty(t, created.Annotations[specHashAnnotation])
+	if assert.Len(t, created.OwnerReferences, 1) {
+		assert.Equal(t, prov.Name, created.OwnerReferences[0].Name)
+	}
+
+	// Nothing is written when nothing changed
+	live := &appsv1.Deployment{}
+	assert.NoError(t, reconciler.Client.Get(ctx, key, live))
+	assert.Equal(t, live.ResourceVersion, apply().ResourceVersion)
+
+	// Neither when fields were defaulted, or metadata was added, by
+	// someone else
+	live.Annotations["deployment.kubernetes.io/revision"] = "1"
+	live.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+	live.Spec.RevisionHistoryLimit = new(int32)
+	assert.NoError(t, reconciler.Client.Update(ctx, live))
+	assert.Equal(t, live.ResourceVersion, apply().ResourceVersion)
+	assert.Len(t, recorder.Events, 0)
+
+	// Manual edits are reverted, and reported
+	live.Spec.Template.Spec.Containers[0].Image = "quay.io/example/baremetal-operator:dev"
+	assert.NoError(t, reconciler.Client.Update(ctx, live))
+	reverted := apply()
+	assert.NotEqual(t, live.ResourceVersion, reverted.ResourceVersion)
+	assert.Equal(t, images.BaremetalOperator, reverted.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "1", reverted.Annotations["deployment.kubernetes.io/revision"])
+	if assert.Len(t, recorder.Events, 1) {
+		event := <-recorder.Events
+		assert.Contains(t, event, "Warning ManualChangesReverted")
+		assert.Contains(t, event, "spec.template.spec.containers[0].image")
+	}
+
+	// Changes to the rendered content are applied without an event
+	prov.Spec.ExternalIronicIP = "192.168.111.20"
+	updated := apply()
+	assert.NotEqual(t, reverted.ResourceVersion, updated.ResourceVersion)
+	assert.NotEqual(t, reverted.Annotations[specHashAnnotation], updated.Annotations[specHashAnnotation])
+	assert.Len(t, recorder.Events, 0)
+}
+
+func TestApplyServiceKeepsClusterIP(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkManaged,
+			ProvisioningIP:      "172.30.20.3",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), prov)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.IronicServiceName}
+	apply := func() *corev1.Service {
+		service := &corev1.Service{}
+		for _, obj := range provisioning.NewServiceManifests(prov, ComponentNamespace) {
+			if desired, ok := obj.(*corev1.Service); ok && desired.Name == key.Name {
+				assert.NoError(t, reconciler.apply(prov, desired, service))
+			}
+		}
+		return service
+	}
+
+	// The API server allocates the cluster IP
+	apply()
+	live := &corev1.Service{}
+	assert.NoError(t, reconciler.Client.Get(ctx, key, live))
+	live.Spec.ClusterIP = "172.30.114.5"
+	assert.NoError(t, reconciler.Client.Update(ctx, live))
+
+	prov.Spec.ProvisioningNetwork = metal3iov1alpha1.ProvisioningNetworkDisabled
+	prov.Spec.ExternalIronicIP = "192.168.111.10"
+	updated := apply()
+	assert.NotEqual(t, live.ResourceVersion, updated.ResourceVersion)
+	assert.Equal(t, provisioning.IronicProxyLabels, updated.Spec.Selector)
+	assert.Equal(t, "172.30.114.5", updated.Spec.ClusterIP)
+}
+
+func TestDiffPaths(t *testing.T) {
+	testCases := []struct {
+		name          string
+		desired       map[string]interface{}
+		live          map[string]interface{}
+		expectedPaths []string
+	}{
+		{
+			name:    "Equal",
+			desired: map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+			live:    map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+		},
+		{
+			name:    "ExtraLiveFields",
+			desired: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:    map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1), "paused": false}},
+		},
+		{
+			name:          "ChangedValue",
+			desired:       map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "2"}},
+			live:          map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "3"}},
+			expectedPaths: []string{"data.b"},
+		},
+		{
+			name:          "MissingField",
+			desired:       map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+			live:          map[string]interface{}{},
+			expectedPaths: []string{"data"},
+		},
+		{
+			name: "ListElement",
+			desired: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a", "image": "a:1"},
+			}},
+			live: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a", "image": "a:2", "terminationMessagePath": "/dev/termination-log"},
+			}},
+			expectedPaths: []string{"containers[0].image"},
+		},
+		{
+			name: "ListLength",
+			desired: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a"},
+			}},
+			live: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a"},
+				map[string]interface{}{"name": "b"},
+			}},
+			expectedPaths: []string{"containers"},
+		},
+		{
+			name:    "EmptyDesired",
+			desired: map[string]interface{}{"resources": map[string]interface{}{}, "args": []interface{}{}},
+			live:    map[string]interface{}{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedPaths, diffPaths("", tc.desired, tc.live))
+		})
+	}
+}
@@ -30,6 +30,12 @@ const (
 	ReasonSyncing StatusReason = "SyncingResources"
 	// ReasonSyncFailed is an failed StatusReason
 	ReasonSyncFailed StatusReason = "SyncingFailed"
+	// ReasonMissingImages is the StatusReason for missing or invalid
+	// metal3 images in the release payload
+	ReasonMissingImages StatusReason = "MissingImages"
+	// ReasonUnschedulable is the StatusReason for metal3 pods that no
+	// node can run
+	ReasonUnschedulable StatusReason = "PodsUnschedulable"
 	// ReasonUnsupported is an unsupported StatusReason
 	ReasonUnsupported StatusReason = "UnsupportedPlatform"
 )
@@ -149,3 +155,50 @@ func (r *ProvisioningReconciler) updateCOStatusDisabled() error {
 
 	return r.syncStatus(co, conds)
 }
+
+// updateCOStatus updates the ClusterOperator's status based on the
+// reason for the update. ReasonSyncFailed and ReasonMissingImages mark
+// the operator Degraded, ReasonSyncing Progressing and ReasonComplete
+// Available. ReasonUnschedulable marks it both Degraded and Progressing,
+// as the rollout is stuck until the pods can be scheduled. Any other
+// reason clears a previously reported degradation.
+func (r *ProvisioningReconciler) updateCOStatus(newReason StatusReason, msg, progressMsg string) error {
+	co, err := r.getOrCreateClusterOperator()
+	if err != nil {
+		r.Log.Error(err, "failed to get or create ClusterOperator")
+		return err
+	}
+
+	conds := []osconfigv1.ClusterOperatorStatusCondition{
+		setStatusCondition(OperatorDisabled, osconfigv1.ConditionFalse, "", ""),
+	}
+	switch newReason {
+	case ReasonSyncFailed, ReasonMissingImages:
+		conds = append(conds,
+			setStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionTrue, string(newReason), msg),
+			setStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionFalse, string(newReason), progressMsg),
+		)
+	case ReasonUnschedulable:
+		conds = append(conds,
+			setStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionTrue, string(newReason), msg),
+			setStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionTrue, string(newReason), progressMsg),
+		)
+	case ReasonSyncing:
+		conds = append(conds,
+			setStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionFalse, string(newReason), msg),
+			setStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionTrue, string(newReason), progressMsg),
+		)
+	case ReasonComplete:
+		conds = append(conds,
+			setStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionFalse, string(newReason), msg),
+			setStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionFalse, string(newReason), progressMsg),
+			setStatusCondition(osconfigv1.OperatorAvailable, osconfigv1.ConditionTrue, string(newReason), "metal3 is available"),
+		)
+	default:
+		conds = append(conds,
+			setStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionFalse, string(newReason), msg),
+		)
+	}
+
+	return r.syncStatus(co, conds)
+}
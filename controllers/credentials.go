@@ -0,0 +1,128 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// apiCredentials are the Secrets holding the credentials of the ironic
+// and ironic-inspector APIs, and the user each is generated for.
+var apiCredentials = []struct {
+	secretName string
+	username   string
+}{
+	{provisioning.IronicCredentialsSecretName, provisioning.IronicUsername},
+	{provisioning.InspectorCredentialsSecretName, provisioning.InspectorUsername},
+}
+
+// ensureCredentials creates the Secrets holding the mariadb password and
+// the credentials of the ironic and ironic-inspector APIs which do not
+// exist yet. Existing Secrets are never regenerated, so that the
+// services keep accepting the credentials their clients use. Only the
+// names of the Secrets are logged, never their contents.
+func (r *ProvisioningReconciler) ensureCredentials() error {
+	if err := r.ensureSecret(provisioning.MariadbPasswordSecretName, func() (map[string][]byte, error) {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{provisioning.MariadbPasswordSecretKey: []byte(password)}, nil
+	}); err != nil {
+		return err
+	}
+
+	for _, api := range apiCredentials {
+		username := api.username
+		if err := r.ensureSecret(api.secretName, func() (map[string][]byte, error) {
+			return newAPICredentials(username)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureSecret creates the Secret called name in the namespace of the
+// operator with the data returned by generate, unless it exists.
+func (r *ProvisioningReconciler) ensureSecret(name string, generate func() (map[string][]byte, error)) error {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: ComponentNamespace, Name: name}
+
+	err := r.Client.Get(ctx, key, &corev1.Secret{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "unable to get Secret %s/%s", key.Namespace, key.Name)
+	}
+
+	data, err := generate()
+	if err != nil {
+		return errors.Wrapf(err, "unable to generate Secret %s/%s", key.Namespace, key.Name)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}
+	if err := r.Client.Create(ctx, secret, fieldOwner); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to create Secret %s/%s", key.Namespace, key.Name)
+	}
+	r.Log.Info("generated credentials", "secret", key.Name)
+	return nil
+}
+
+// newAPICredentials returns the data of a Secret holding the
+// credentials of username: the client credentials and the htpasswd
+// file the API checks them against.
+func newAPICredentials(username string) (map[string][]byte, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	htpasswd, err := provisioning.Htpasswd(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		provisioning.UsernameKey: []byte(username),
+		provisioning.PasswordKey: []byte(password),
+		provisioning.HtpasswdKey: []byte(htpasswd),
+	}, nil
+}
+
+// generatePassword returns a random password of 16 bytes of entropy.
+func generatePassword() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "unable to generate password")
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
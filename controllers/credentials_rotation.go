@@ -0,0 +1,278 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+const (
+	// credentialsHashAnnotation is set on the pod template of the metal3
+	// Deployment to the hash of the credentials Secrets, so that the pod
+	// is restarted whenever they change.
+	credentialsHashAnnotation = "baremetal.openshift.io/credentials-hash"
+
+	// pendingUsernameKey and pendingPasswordKey hold the new client
+	// credentials of an API while a rotation is in progress. They are
+	// not mounted in the pod.
+	pendingUsernameKey = "pendingUsername"
+	pendingPasswordKey = "pendingPassword"
+)
+
+// credentialsSecretNames are the Secrets whose contents are hashed into
+// credentialsHashAnnotation. The serving certificate is among them, as
+// the APIs only load it on startup.
+var credentialsSecretNames = []string{
+	provisioning.MariadbPasswordSecretName,
+	provisioning.IronicCredentialsSecretName,
+	provisioning.InspectorCredentialsSecretName,
+	provisioning.TLSSecretName,
+}
+
+// rotateCredentials advances the rotation of the credentials requested
+// with RotateCredentialsAnnotation. Each step changes the Secrets, and so
+// restarts the metal3 pod, and the next step only happens once a pod
+// using them is available. The APIs first accept the new credentials
+// along with the old ones, then the clients switch to the new ones and
+// the mariadb password is regenerated, and last the APIs stop accepting
+// the old credentials, so that the clients always use credentials the
+// APIs accept.
+func (r *ProvisioningReconciler) rotateCredentials(prov *metal3iov1alpha1.Provisioning) error {
+	rotation := prov.Status.CredentialsRotation
+	if rotation == nil {
+		rotation = &metal3iov1alpha1.CredentialsRotationStatus{}
+	}
+
+	if rotation.Phase == "" {
+		request := prov.Annotations[metal3iov1alpha1.RotateCredentialsAnnotation]
+		if request == "" || request == rotation.Request {
+			return nil
+		}
+		if err := r.acceptNewCredentials(); err != nil {
+			return err
+		}
+		rotation.Request = request
+		rotation.Phase = metal3iov1alpha1.CredentialsRotationAccepting
+		prov.Status.CredentialsRotation = rotation
+		r.Log.Info("rotating credentials", "request", request)
+		r.EventRecorder.Eventf(prov, corev1.EventTypeNormal, "CredentialsRotationStarted",
+			"rotating the metal3 credentials as requested by annotation %s", metal3iov1alpha1.RotateCredentialsAnnotation)
+		return nil
+	}
+
+	rolledOut, err := r.credentialsRolledOut()
+	if err != nil || !rolledOut {
+		return err
+	}
+
+	switch rotation.Phase {
+	case metal3iov1alpha1.CredentialsRotationAccepting:
+		if err := r.switchToNewCredentials(); err != nil {
+			return err
+		}
+		rotation.Phase = metal3iov1alpha1.CredentialsRotationSwitching
+	case metal3iov1alpha1.CredentialsRotationSwitching:
+		if err := r.revokeOldCredentials(); err != nil {
+			return err
+		}
+		rotation.Phase = metal3iov1alpha1.CredentialsRotationRevoking
+	default:
+		now := metav1.Now()
+		rotation.Phase = ""
+		rotation.LastRotationTime = &now
+		r.Log.Info("rotated credentials", "request", rotation.Request)
+		r.EventRecorder.Event(prov, corev1.EventTypeNormal, "CredentialsRotated",
+			"the metal3 credentials were rotated")
+		return nil
+	}
+	r.Log.Info("rotating credentials", "phase", rotation.Phase)
+	return nil
+}
+
+// acceptNewCredentials generates the new client credentials of the
+// APIs, and makes their htpasswd files accept them along with the
+// current ones.
+func (r *ProvisioningReconciler) acceptNewCredentials() error {
+	for _, api := range apiCredentials {
+		secret, err := r.getSecret(api.secretName)
+		if err != nil {
+			return err
+		}
+		suffix, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		username := fmt.Sprintf("%s-%s", api.username, suffix[:6])
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		current, err := htpasswdOf(secret.Data[provisioning.UsernameKey], secret.Data[provisioning.PasswordKey])
+		if err != nil {
+			return err
+		}
+		pending, err := htpasswdOf([]byte(username), []byte(password))
+		if err != nil {
+			return err
+		}
+		secret.Data[pendingUsernameKey] = []byte(username)
+		secret.Data[pendingPasswordKey] = []byte(password)
+		secret.Data[provisioning.HtpasswdKey] = []byte(current + "\n" + pending)
+		if err := r.updateSecret(secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// switchToNewCredentials makes the new credentials of the APIs the ones
+// their clients use, and regenerates the mariadb password.
+func (r *ProvisioningReconciler) switchToNewCredentials() error {
+	for _, api := range apiCredentials {
+		secret, err := r.getSecret(api.secretName)
+		if err != nil {
+			return err
+		}
+		username, found := secret.Data[pendingUsernameKey]
+		if !found {
+			// Already switched
+			continue
+		}
+		secret.Data[provisioning.UsernameKey] = username
+		secret.Data[provisioning.PasswordKey] = secret.Data[pendingPasswordKey]
+		delete(secret.Data, pendingUsernameKey)
+		delete(secret.Data, pendingPasswordKey)
+		if err := r.updateSecret(secret); err != nil {
+			return err
+		}
+	}
+
+	secret, err := r.getSecret(provisioning.MariadbPasswordSecretName)
+	if err != nil {
+		return err
+	}
+	password, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	secret.Data = map[string][]byte{provisioning.MariadbPasswordSecretKey: []byte(password)}
+	return r.updateSecret(secret)
+}
+
+// revokeOldCredentials makes the htpasswd files of the APIs only accept
+// the credentials their clients use.
+func (r *ProvisioningReconciler) revokeOldCredentials() error {
+	for _, api := range apiCredentials {
+		secret, err := r.getSecret(api.secretName)
+		if err != nil {
+			return err
+		}
+		htpasswd, err := htpasswdOf(secret.Data[provisioning.UsernameKey], secret.Data[provisioning.PasswordKey])
+		if err != nil {
+			return err
+		}
+		secret.Data[provisioning.HtpasswdKey] = []byte(htpasswd)
+		if err := r.updateSecret(secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentialsRolledOut reports whether the metal3 pod using the current
+// contents of the credentials Secrets is available.
+func (r *ProvisioningReconciler) credentialsRolledOut() (bool, error) {
+	hash, err := r.credentialsHash()
+	if err != nil {
+		return false, err
+	}
+
+	deployment := &appsv1.Deployment{}
+	key := client.ObjectKey{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}
+	if err := r.Client.Get(context.Background(), key, deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "unable to get Deployment %s/%s", key.Namespace, key.Name)
+	}
+	if deployment.Spec.Template.Annotations[credentialsHashAnnotation] != hash {
+		return false, nil
+	}
+	available, _ := deploymentAvailable(deployment)
+	return available, nil
+}
+
+// credentialsHash returns the hash of the contents of the credentials
+// Secrets.
+func (r *ProvisioningReconciler) credentialsHash() (string, error) {
+	hash := sha256.New()
+	for _, name := range credentialsSecretNames {
+		secret, err := r.getSecret(name)
+		if err != nil {
+			return "", err
+		}
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(hash, "%s\n", name)
+		for _, key := range keys {
+			fmt.Fprintf(hash, "%s=%x\n", key, secret.Data[key])
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (r *ProvisioningReconciler) getSecret(name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: ComponentNamespace, Name: name}
+	if err := r.Client.Get(context.Background(), key, secret); err != nil {
+		return nil, errors.Wrapf(err, "unable to get Secret %s/%s", key.Namespace, key.Name)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	return secret, nil
+}
+
+func (r *ProvisioningReconciler) updateSecret(secret *corev1.Secret) error {
+	if err := r.Client.Update(context.Background(), secret, fieldOwner); err != nil {
+		return errors.Wrapf(err, "unable to update Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	r.Log.Info("updated Secret", "name", secret.Name)
+	return nil
+}
+
+// htpasswdOf returns the htpasswd line of the client credentials
+// username and password.
+func htpasswdOf(username, password []byte) (string, error) {
+	return provisioning.Htpasswd(string(username), string(password))
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// accepted reports whether the htpasswd file of secret accepts the
+// client credentials in it.
+func accepted(secret *corev1.Secret) bool {
+	for _, line := range strings.Split(string(secret.Data[provisioning.HtpasswdKey]), "\n") {
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) == 2 && fields[0] == string(secret.Data[provisioning.UsernameKey]) &&
+			bcrypt.CompareHashAndPassword([]byte(fields[1]), secret.Data[provisioning.PasswordKey]) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRotateCredentials(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+
+	reconcile := func() *metal3iov1alpha1.CredentialsRotationStatus {
+		_, err := reconciler.Reconcile(req)
+		assert.NoError(t, err)
+		got := &metal3iov1alpha1.Provisioning{}
+		assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got))
+		return got.Status.CredentialsRotation
+	}
+	getSecret := func(name string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: name}, secret))
+		return secret
+	}
+	getDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}, deployment))
+		return deployment
+	}
+	setAvailable := func(available bool) {
+		deployment := getDeployment()
+		deployment.Status.ObservedGeneration = deployment.Generation
+		deployment.Status.UpdatedReplicas, deployment.Status.AvailableReplicas = 0, 0
+		if available {
+			deployment.Status.UpdatedReplicas, deployment.Status.AvailableReplicas = 1, 1
+		}
+		assert.NoError(t, reconciler.Client.Status().Update(ctx, deployment))
+	}
+	// step reconciles once the pod using the current credentials is
+	// available, and checks that the clients of the APIs keep using
+	// credentials the APIs accept.
+	step := func() *metal3iov1alpha1.CredentialsRotationStatus {
+		hash := getDeployment().Spec.Template.Annotations[credentialsHashAnnotation]
+		setAvailable(true)
+		rotation := reconcile()
+		if getDeployment().Spec.Template.Annotations[credentialsHashAnnotation] != hash {
+			// The pod restarts with the new credentials
+			setAvailable(false)
+		}
+		for _, api := range apiCredentials {
+			assert.True(t, accepted(getSecret(api.secretName)), api.secretName)
+		}
+		return rotation
+	}
+
+	assert.Nil(t, reconcile())
+	assert.Nil(t, step())
+	oldIronic := getSecret(provisioning.IronicCredentialsSecretName)
+	oldMariadb := getSecret(provisioning.MariadbPasswordSecretName)
+	oldHash := getDeployment().Spec.Template.Annotations[credentialsHashAnnotation]
+	assert.NotEmpty(t, oldHash)
+
+	// Nothing happens until the annotation is set
+	assert.Nil(t, step())
+	assert.Equal(t, oldHash, getDeployment().Spec.Template.Annotations[credentialsHashAnnotation])
+
+	assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, prov))
+	prov.Annotations = map[string]string{metal3iov1alpha1.RotateCredentialsAnnotation: "2020-10-01"}
+	assert.NoError(t, reconciler.Client.Update(ctx, prov))
+
+	rotation := step()
+	if !assert.NotNil(t, rotation) {
+		t.FailNow()
+	}
+	assert.Equal(t, metal3iov1alpha1.CredentialsRotationAccepting, rotation.Phase)
+	assert.Equal(t, "2020-10-01", rotation.Request)
+	assert.NotEqual(t, oldHash, getDeployment().Spec.Template.Annotations[credentialsHashAnnotation])
+	ironic := getSecret(provisioning.IronicCredentialsSecretName)
+	assert.Equal(t, oldIronic.Data[provisioning.UsernameKey], ironic.Data[provisioning.UsernameKey])
+	assert.Len(t, strings.Split(string(ironic.Data[provisioning.HtpasswdKey]), "\n"), 2)
+
+	// The rotation waits for the pod to be available
+	assert.Equal(t, metal3iov1alpha1.CredentialsRotationAccepting, reconcile().Phase)
+
+	rotation = step()
+	assert.Equal(t, metal3iov1alpha1.CredentialsRotationSwitching, rotation.Phase)
+	ironic = getSecret(provisioning.IronicCredentialsSecretName)
+	assert.NotEqual(t, oldIronic.Data[provisioning.UsernameKey], ironic.Data[provisioning.UsernameKey])
+	assert.NotEqual(t, oldIronic.Data[provisioning.PasswordKey], ironic.Data[provisioning.PasswordKey])
+	assert.NotContains(t, ironic.Data, pendingUsernameKey)
+	assert.NotContains(t, ironic.Data, pendingPasswordKey)
+	assert.NotEqual(t, oldMariadb.Data, getSecret(provisioning.MariadbPasswordSecretName).Data)
+
+	rotation = step()
+	assert.Equal(t, metal3iov1alpha1.CredentialsRotationRevoking, rotation.Phase)
+	htpasswd := string(getSecret(provisioning.IronicCredentialsSecretName).Data[provisioning.HtpasswdKey])
+	assert.NotContains(t, htpasswd, "\n")
+	assert.True(t, strings.HasPrefix(htpasswd, string(ironic.Data[provisioning.UsernameKey])+":"))
+
+	rotation = step()
+	assert.Equal(t, metal3iov1alpha1.CredentialsRotationPhase(""), rotation.Phase)
+	assert.NotNil(t, rotation.LastRotationTime)
+
+	// The same request is not acted upon twice
+	hash := getDeployment().Spec.Template.Annotations[credentialsHashAnnotation]
+	assert.Equal(t, rotation, step())
+	assert.Equal(t, hash, getDeployment().Spec.Template.Annotations[credentialsHashAnnotation])
+}
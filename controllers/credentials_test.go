@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+func TestEnsureCredentials(t *testing.T) {
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ComponentNamespace,
+			Name:      provisioning.MariadbPasswordSecretName,
+		},
+		Data: map[string][]byte{provisioning.MariadbPasswordSecretKey: []byte("kept")},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), existing)
+	ctx := context.Background()
+
+	getSecret := func(name string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: name}, secret))
+		return secret
+	}
+
+	assert.NoError(t, reconciler.ensureCredentials())
+
+	// An existing Secret is not regenerated
+	assert.Equal(t, "kept", string(getSecret(provisioning.MariadbPasswordSecretName).Data[provisioning.MariadbPasswordSecretKey]))
+
+	for name, username := range map[string]string{
+		provisioning.IronicCredentialsSecretName:    provisioning.IronicUsername,
+		provisioning.InspectorCredentialsSecretName: provisioning.InspectorUsername,
+	} {
+		secret := getSecret(name)
+		assert.Equal(t, corev1.SecretTypeOpaque, secret.Type)
+		assert.Equal(t, username, string(secret.Data[provisioning.UsernameKey]))
+		password := secret.Data[provisioning.PasswordKey]
+		assert.Len(t, password, 22)
+
+		htpasswd := strings.SplitN(string(secret.Data[provisioning.HtpasswdKey]), ":", 2)
+		if assert.Len(t, htpasswd, 2) {
+			assert.Equal(t, username, htpasswd[0])
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(htpasswd[1]), password))
+		}
+
+		// Nor is a generated one
+		assert.NoError(t, reconciler.ensureCredentials())
+		assert.Equal(t, secret.Data, getSecret(name).Data)
+	}
+}
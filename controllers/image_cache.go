@@ -0,0 +1,172 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/downloader"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+
+// imageCachePollInterval is how often the image cache pods are looked at
+// while they download or verify the image, as their progress does not
+// change the DaemonSet status.
+const imageCachePollInterval = 30 * time.Second
+
+// setImageCacheStatus records the state of the image cache run by
+// daemonSet, which is nil when there is no OS image to cache.
+func (r *ProvisioningReconciler) setImageCacheStatus(prov *metal3iov1alpha1.Provisioning, daemonSet *appsv1.DaemonSet) error {
+	if daemonSet == nil {
+		prov.Status.ImageCache = nil
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.Client.List(context.Background(), pods,
+		client.InNamespace(ComponentNamespace), client.MatchingLabels(provisioning.ImageCacheLabels)); err != nil {
+		return errors.Wrap(err, "unable to list the image cache pods")
+	}
+	status := imageCacheStatus(daemonSet, pods.Items)
+	status.URL = provisioning.ImageCacheURL(prov, provisioning.HTTPIP(prov))
+	prov.Status.ImageCache = status
+	return nil
+}
+
+// imageCacheStatus aggregates the state of the image on the nodes
+// running the pods of daemonSet. It is Failed when a node failed,
+// otherwise it is the phase of the node that is the furthest behind.
+// The checksum is the one the nodes verified the image against, once
+// they all did.
+func imageCacheStatus(daemonSet *appsv1.DaemonSet, pods []corev1.Pod) *metal3iov1alpha1.ImageCacheStatus {
+	rank := map[metal3iov1alpha1.ImageCachePhase]int{
+		metal3iov1alpha1.ImageCacheFailed:      0,
+		metal3iov1alpha1.ImageCacheDownloading: 1,
+		metal3iov1alpha1.ImageCacheVerifying:   2,
+		metal3iov1alpha1.ImageCacheDownloaded:  3,
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Spec.NodeName < pods[j].Spec.NodeName })
+
+	status := &metal3iov1alpha1.ImageCacheStatus{
+		Phase:        metal3iov1alpha1.ImageCacheDownloaded,
+		DesiredNodes: daemonSet.Status.DesiredNumberScheduled,
+	}
+	messages := []string{}
+	checksum := ""
+	for _, pod := range pods {
+		progress, msg := podImageCache(&pod)
+		status.DownloadedBytes += progress.DownloadedBytes
+		status.TotalBytes += progress.TotalBytes
+		if msg != "" {
+			messages = append(messages, msg)
+		}
+		if progress.Phase == metal3iov1alpha1.ImageCacheDownloaded {
+			status.CachedNodes++
+			if checksum == "" {
+				checksum = progress.SHA256
+			}
+			continue
+		}
+		if rank[progress.Phase] < rank[status.Phase] {
+			status.Phase = progress.Phase
+		}
+	}
+	// Until the DaemonSet controller schedules pods, no node caches it
+	if status.Phase == metal3iov1alpha1.ImageCacheDownloaded &&
+		(status.DesiredNodes == 0 || status.CachedNodes < status.DesiredNodes) {
+		status.Phase = metal3iov1alpha1.ImageCacheDownloading
+	}
+	if status.Phase == metal3iov1alpha1.ImageCacheDownloaded {
+		status.SHA256 = checksum
+	} else {
+		messages = append(messages, fmt.Sprintf("%d of %d nodes cache the image", status.CachedNodes, status.DesiredNodes))
+	}
+	status.Message = strings.Join(messages, "; ")
+	return status
+}
+
+// podImageCache returns the state of the image on the node of pod, as
+// published by its download container, and why the download failed or
+// is being retried.
+func podImageCache(pod *corev1.Pod) (downloader.Progress, string) {
+	progress := downloader.Progress{Phase: metal3iov1alpha1.ImageCacheDownloading}
+	published, err := downloader.PodProgress(pod)
+	if err != nil {
+		return progress, err.Error()
+	}
+	if published != nil {
+		progress = *published
+	}
+
+	var container *corev1.ContainerStatus
+	for i, s := range pod.Status.InitContainerStatuses {
+		if s.Name == provisioning.ImageCacheDownloadContainer {
+			container = &pod.Status.InitContainerStatuses[i]
+		}
+	}
+	if container == nil {
+		progress.Phase = metal3iov1alpha1.ImageCacheDownloading
+		return progress, ""
+	}
+
+	terminated := container.State.Terminated
+	if terminated != nil && terminated.ExitCode == 0 {
+		progress.Phase = metal3iov1alpha1.ImageCacheDownloaded
+		return progress, ""
+	}
+	if terminated == nil {
+		// Restarted after a failure
+		terminated = container.LastTerminationState.Terminated
+	}
+	if terminated != nil && terminated.ExitCode != 0 {
+		msg := fmt.Sprintf("%s failed on node %s with exit code %d", container.Name, pod.Spec.NodeName, terminated.ExitCode)
+		if progress.Message != "" {
+			msg += ": " + progress.Message
+		}
+		progress.Phase = metal3iov1alpha1.ImageCacheFailed
+		return progress, msg
+	}
+
+	if progress.Phase != metal3iov1alpha1.ImageCacheVerifying {
+		progress.Phase = metal3iov1alpha1.ImageCacheDownloading
+	}
+	if progress.Message != "" {
+		return progress, fmt.Sprintf("%s on node %s is retrying: %s", container.Name, pod.Spec.NodeName, progress.Message)
+	}
+	return progress, ""
+}
+
+// imageCacheInProgress reports whether the image is being downloaded or
+// verified.
+func imageCacheInProgress(status *metal3iov1alpha1.ImageCacheStatus) bool {
+	return status != nil && (status.Phase == metal3iov1alpha1.ImageCacheDownloading ||
+		status.Phase == metal3iov1alpha1.ImageCacheVerifying)
+}
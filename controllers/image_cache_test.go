@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/downloader"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+func TestImageCacheStatus(t *testing.T) {
+	const checksum = "c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7"
+	done := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}
+	failed := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	waiting := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	pod := func(node string, download *corev1.ContainerState, progress string) corev1.Pod {
+		p := corev1.Pod{Spec: corev1.PodSpec{NodeName: node}}
+		if download != nil {
+			p.Status.InitContainerStatuses = append(p.Status.InitContainerStatuses,
+				corev1.ContainerStatus{Name: provisioning.ImageCacheDownloadContainer, State: *download})
+		}
+		if progress != "" {
+			p.Annotations = map[string]string{downloader.ProgressAnnotation: progress}
+		}
+		return p
+	}
+	downloaded := `{"phase":"Downloaded","attempt":1,"downloadedBytes":1000,"totalBytes":1000,"sha256":"` + checksum + `"}`
+	crashLooping := pod("master-1", &waiting, `{"phase":"Failed","attempt":8,"downloadedBytes":1000,"totalBytes":1000,"message":"sha256 mismatch"}`)
+	crashLooping.Status.InitContainerStatuses[0].LastTerminationState = failed
+
+	testCases := []struct {
+		name             string
+		pods             []corev1.Pod
+		expectedPhase    metal3iov1alpha1.ImageCachePhase
+		expectedCached   int32
+		expectedBytes    int64
+		expectedTotal    int64
+		expectedChecksum string
+		expectedMessage  string
+	}{
+		{
+			name:            "NotScheduled",
+			expectedPhase:   metal3iov1alpha1.ImageCacheDownloading,
+			expectedMessage: "0 of 3 nodes cache the image",
+		},
+		{
+			name: "Downloading",
+			pods: []corev1.Pod{
+				pod("master-0", &done, downloaded),
+				pod("master-1", &running, `{"phase":"Verifying","attempt":1,"downloadedBytes":1000,"totalBytes":1000}`),
+				pod("master-2", &running, `{"phase":"Downloading","attempt":2,"downloadedBytes":200,"totalBytes":1000,"message":"download truncated to 500 of 1000 bytes"}`),
+			},
+			expectedPhase:  metal3iov1alpha1.ImageCacheDownloading,
+			expectedCached: 1,
+			expectedBytes:  2200,
+			expectedTotal:  3000,
+			expectedMessage: "metal3-image-cache-download on node master-2 is retrying: download truncated to 500 of 1000 bytes; " +
+				"1 of 3 nodes cache the image",
+		},
+		{
+			name: "Verifying",
+			pods: []corev1.Pod{
+				pod("master-0", &done, downloaded),
+				pod("master-1", &running, `{"phase":"Verifying","attempt":1,"downloadedBytes":1000,"totalBytes":1000}`),
+				pod("master-2", &done, downloaded),
+			},
+			expectedPhase:   metal3iov1alpha1.ImageCacheVerifying,
+			expectedCached:  2,
+			expectedBytes:   3000,
+			expectedTotal:   3000,
+			expectedMessage: "2 of 3 nodes cache the image",
+		},
+		{
+			name: "NotReportedYet",
+			pods: []corev1.Pod{
+				pod("master-0", nil, ""),
+				pod("master-1", &running, ""),
+				pod("master-2", &running, "{"),
+			},
+			expectedPhase:   metal3iov1alpha1.ImageCacheDownloading,
+			expectedMessage: "could not parse annotation baremetal.openshift.io/image-download of pod : unexpected end of JSON input; 0 of 3 nodes cache the image",
+		},
+		{
+			name: "Failed",
+			pods: []corev1.Pod{
+				pod("master-2", &failed, ""),
+				pod("master-0", &running, ""),
+				crashLooping,
+			},
+			expectedPhase: metal3iov1alpha1.ImageCacheFailed,
+			expectedBytes: 1000,
+			expectedTotal: 1000,
+			expectedMessage: "metal3-image-cache-download failed on node master-1 with exit code 1: sha256 mismatch; " +
+				"metal3-image-cache-download failed on node master-2 with exit code 1; 0 of 3 nodes cache the image",
+		},
+		{
+			name: "Downloaded",
+			pods: []corev1.Pod{
+				pod("master-0", &done, downloaded),
+				pod("master-1", &done, downloaded),
+				pod("master-2", &done, downloaded),
+			},
+			expectedPhase:    metal3iov1alpha1.ImageCacheDownloaded,
+			expectedCached:   3,
+			expectedBytes:    3000,
+			expectedTotal:    3000,
+			expectedChecksum: checksum,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			daemonSet := &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3}}
+			status := imageCacheStatus(daemonSet, tc.pods)
+			assert.Equal(t, tc.expectedPhase, status.Phase)
+			assert.Equal(t, tc.expectedCached, status.CachedNodes)
+			assert.Equal(t, int32(3), status.DesiredNodes)
+			assert.Equal(t, tc.expectedBytes, status.DownloadedBytes)
+			assert.Equal(t, tc.expectedTotal, status.TotalBytes)
+			assert.Equal(t, tc.expectedChecksum, status.SHA256)
+			assert.Equal(t, tc.expectedMessage, status.Message)
+		})
+	}
+}
+
+func TestReconcileImageCache(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:          "192.168.111.10",
+			ProvisioningOSDownloadURL: "https://releases.example.com/rhcos/rhcos.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+
+	result, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, imageCachePollInterval, result.RequeueAfter)
+
+	daemonSet := &appsv1.DaemonSet{}
+	assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.ImageCacheDaemonSetName}, daemonSet))
+
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) && assert.NotNil(t, got.Status.ImageCache) {
+		assert.Equal(t, metal3iov1alpha1.ImageCacheDownloading, got.Status.ImageCache.Phase)
+		assert.Equal(t, "http://192.168.111.10:6181/images/rhcos.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7",
+			got.Status.ImageCache.URL)
+	}
+	binding := &rbacv1.RoleBinding{}
+	assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.ImageCacheServiceAccountName}, binding))
+
+	// The only node verified the image
+	daemonSet.Status.DesiredNumberScheduled = 1
+	assert.NoError(t, reconciler.Client.Update(ctx, daemonSet))
+	assert.NoError(t, reconciler.Client.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ComponentNamespace,
+			Name:      "metal3-image-cache-x7k2p",
+			Labels:    provisioning.ImageCacheLabels,
+			Annotations: map[string]string{
+				downloader.ProgressAnnotation: `{"phase":"Downloaded","attempt":1,"downloadedBytes":1000,"totalBytes":1000,"sha256":"c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7"}`,
+			},
+		},
+		Spec: corev1.PodSpec{NodeName: "master-0"},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  provisioning.ImageCacheDownloadContainer,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}},
+			}},
+		},
+	}))
+	result, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.NotEqual(t, imageCachePollInterval, result.RequeueAfter)
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) && assert.NotNil(t, got.Status.ImageCache) {
+		assert.Equal(t, metal3iov1alpha1.ImageCacheDownloaded, got.Status.ImageCache.Phase)
+		assert.Equal(t, "c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7", got.Status.ImageCache.SHA256)
+		assert.Equal(t, int64(1000), got.Status.ImageCache.DownloadedBytes)
+	}
+
+	// Without an image to cache, the image cache is removed
+	got.Spec.ProvisioningOSDownloadURL = ""
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.ImageCacheServiceAccountName}, binding)
+	assert.True(t, apierrors.IsNotFound(err))
+	removed := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, removed)) {
+		assert.Nil(t, removed.Status.ImageCache)
+	}
+}
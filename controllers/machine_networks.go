@@ -0,0 +1,119 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+)
+
+const (
+	// installConfigNamespace and installConfigName locate the ConfigMap
+	// in which the installer stores the install-config of the cluster.
+	installConfigNamespace = "kube-system"
+	installConfigName      = "cluster-config-v1"
+	installConfigKey       = "install-config"
+)
+
+// installConfig is the part of the install-config describing the
+// machine networks. Neither the Infrastructure nor the Network config
+// carry it.
+type installConfig struct {
+	Networking struct {
+		// MachineCIDR is the field used by older installers
+		MachineCIDR    string `json:"machineCIDR,omitempty"`
+		MachineNetwork []struct {
+			CIDR string `json:"cidr"`
+		} `json:"machineNetwork,omitempty"`
+	} `json:"networking"`
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// readMachineNetworks returns the machine networks of the cluster, and
+// the IPs of the Infrastructure config which live on them. The machine
+// networks are empty when the cluster was not installed with an
+// install-config.
+func (r *ProvisioningReconciler) readMachineNetworks() ([]*net.IPNet, []string, error) {
+	ctx := context.Background()
+
+	infra := &osconfigv1.Infrastructure{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: "cluster"}, infra); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read Infrastructure config")
+	}
+	reservedIPs := infrastructureIPs(infra)
+
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: installConfigNamespace, Name: installConfigName}, cm)
+	if apierrors.IsNotFound(err) {
+		r.Log.V(1).Info("install-config not found, machine networks unknown")
+		return nil, reservedIPs, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to read %s/%s", installConfigNamespace, installConfigName)
+	}
+
+	machineNetworks, err := parseMachineNetworks([]byte(cm.Data[installConfigKey]))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to read machine networks from %s/%s", installConfigNamespace, installConfigName)
+	}
+	return machineNetworks, reservedIPs, nil
+}
+
+// infrastructureIPs returns the IPs of the Infrastructure config which
+// live on the machine networks.
+func infrastructureIPs(infra *osconfigv1.Infrastructure) []string {
+	var ips []string
+	if status := infra.Status.PlatformStatus; status != nil && status.BareMetal != nil {
+		ips = append(ips, status.BareMetal.APIServerInternalIP, status.BareMetal.IngressIP, status.BareMetal.NodeDNSIP)
+	}
+	return ips
+}
+
+// parseMachineNetworks extracts the machine networks from an
+// install-config.
+func parseMachineNetworks(data []byte) ([]*net.IPNet, error) {
+	config := installConfig{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	cidrs := []string{}
+	for _, network := range config.Networking.MachineNetwork {
+		cidrs = append(cidrs, network.CIDR)
+	}
+	if len(cidrs) == 0 && config.Networking.MachineCIDR != "" {
+		cidrs = append(cidrs, config.Networking.MachineCIDR)
+	}
+
+	machineNetworks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		machineNetworks = append(machineNetworks, network)
+	}
+	return machineNetworks, nil
+}
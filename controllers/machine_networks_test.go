@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestParseMachineNetworks(t *testing.T) {
+	testCases := []struct {
+		name          string
+		installConfig string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name: "MachineNetwork",
+			installConfig: `
+apiVersion: v1
+networking:
+  machineNetwork:
+  - cidr: 192.168.111.0/24
+  - cidr: fd2e:6f44:5dd8:c956::/120
+`,
+			expected: []string{"192.168.111.0/24", "fd2e:6f44:5dd8:c956::/120"},
+		},
+		{
+			name: "MachineCIDR",
+			installConfig: `
+networking:
+  machineCIDR: 192.168.111.0/24
+`,
+			expected: []string{"192.168.111.0/24"},
+		},
+		{
+			name:          "NoNetworking",
+			installConfig: `apiVersion: v1`,
+			expected:      []string{},
+		},
+		{
+			name: "BadCIDR",
+			installConfig: `
+networking:
+  machineNetwork:
+  - cidr: 192.168.111.0/33
+`,
+			expectedError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			networks, err := parseMachineNetworks([]byte(tc.installConfig))
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			cidrs := []string{}
+			for _, network := range networks {
+				cidrs = append(cidrs, network.String())
+			}
+			assert.Equal(t, tc.expected, cidrs)
+		})
+	}
+}
+
+func TestReconcileDisabledExternalIPs(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.BareMetalPlatformType,
+				BareMetal: &configv1.BareMetalPlatformStatus{
+					APIServerInternalIP: "192.168.111.5",
+					IngressIP:           "192.168.111.4",
+				},
+			},
+		},
+	}
+	installConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: installConfigNamespace,
+			Name:      installConfigName,
+		},
+		Data: map[string]string{
+			installConfigKey: "networking:\n  machineNetwork:\n  - cidr: 192.168.111.0/24\n",
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		ironicIP      string
+		installConfig bool
+		expectedValid metav1.ConditionStatus
+	}{
+		{
+			name:          "InMachineNetwork",
+			ironicIP:      "192.168.111.10",
+			installConfig: true,
+			expectedValid: metav1.ConditionTrue,
+		},
+		{
+			name:          "OutsideMachineNetwork",
+			ironicIP:      "172.22.0.3",
+			installConfig: true,
+			expectedValid: metav1.ConditionFalse,
+		},
+		{
+			name:          "APIVIP",
+			ironicIP:      "192.168.111.5",
+			installConfig: true,
+			expectedValid: metav1.ConditionFalse,
+		},
+		{
+			name:          "UnknownMachineNetwork",
+			ironicIP:      "172.22.0.3",
+			expectedValid: metav1.ConditionTrue,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metal3iov1alpha1.ProvisioningSingletonName,
+				},
+				Spec: metal3iov1alpha1.ProvisioningSpec{
+					ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+					ExternalIronicIP:    tc.ironicIP,
+				},
+			}
+			objects := []runtime.Object{infra, prov}
+			if tc.installConfig {
+				objects = append(objects, installConfig)
+			}
+			reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), objects...)
+
+			_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}})
+			assert.NoError(t, err)
+
+			got := &metal3iov1alpha1.Provisioning{}
+			if !assert.NoError(t, reconciler.Client.Get(context.Background(), types.NamespacedName{Name: prov.Name}, got)) {
+				return
+			}
+			valid := meta.FindStatusCondition(got.Status.Conditions, metal3iov1alpha1.ConditionValid)
+			if assert.NotNil(t, valid) {
+				assert.Equal(t, tc.expectedValid, valid.Status, valid.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=services;endpoints,verbs=get;list;watch;create;update;patch;delete
+
+// schedulingPollInterval is how often the metal3 pods are looked at
+// until they are available, as a pod that can't be scheduled changes
+// neither the Deployment nor the DaemonSet status.
+const schedulingPollInterval = time.Minute
+
+// ensureMetal3Deployment creates or updates the metal3 Deployment and
+// the resources it depends on, all owned by the Provisioning CR. When the
+// network mode changes, the Recreate strategy of the Deployment stops the
+// pod before one with the new topology starts, and resources the new
+// topology does not use are deleted once the Deployment was updated. The
+// pod is also restarted whenever the credentials Secrets, the serving
+// certificate or the trusted CA bundle change, as the htpasswd files and
+// the mariadb password are read from the environment and the
+// certificates are only loaded on startup. Changes to the cluster-wide
+// proxy change the environment of the pod, and so restart it too.
+func (r *ProvisioningReconciler) ensureMetal3Deployment(prov *metal3iov1alpha1.Provisioning, images *provisioning.Images) (*appsv1.Deployment, error) {
+	if err := r.ensureCredentials(); err != nil {
+		return nil, err
+	}
+	if err := r.rotateCredentials(prov); err != nil {
+		return nil, err
+	}
+	if err := r.ensureTLS(prov); err != nil {
+		return nil, err
+	}
+	credentialsHash, err := r.credentialsHash()
+	if err != nil {
+		return nil, err
+	}
+	proxy, trustedCABundleHash, err := r.readProxy()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := provisioning.Manifests(prov, images, proxy, ComponentNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var deployment *appsv1.Deployment
+	var imageCache *appsv1.DaemonSet
+	for _, desired := range objects {
+		if d, ok := desired.(*appsv1.Deployment); ok {
+			metav1.SetMetaDataAnnotation(&d.Spec.Template.ObjectMeta, credentialsHashAnnotation, credentialsHash)
+			if trustedCABundleHash != "" {
+				metav1.SetMetaDataAnnotation(&d.Spec.Template.ObjectMeta, trustedCABundleHashAnnotation, trustedCABundleHash)
+			}
+		}
+		live := desired.DeepCopyObject().(object)
+		if err := r.apply(prov, desired.(object), live); err != nil {
+			return nil, err
+		}
+		switch l := live.(type) {
+		case *appsv1.Deployment:
+			deployment = l
+		case *appsv1.DaemonSet:
+			if l.Name == provisioning.ImageCacheDaemonSetName {
+				imageCache = l
+			}
+		}
+	}
+
+	topology := provisioning.NewTopology(prov)
+	if !topology.Dnsmasq {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: provisioning.DnsmasqConfigMapName, Namespace: ComponentNamespace}}
+		if err := r.deleteIfExists(cm); err != nil {
+			return nil, err
+		}
+	}
+	if !topology.ImageCache {
+		for _, obj := range provisioning.NewImageCacheManifests(prov, images, ComponentNamespace) {
+			if err := r.deleteIfExists(obj.(object)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if topology.IronicProxy {
+		// The endpoints controller maintains those of a Service with a
+		// selector
+		if err := r.deleteIfControlled(prov, provisioning.NewIronicEndpoints(prov, ComponentNamespace)); err != nil {
+			return nil, err
+		}
+	} else {
+		ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: provisioning.IronicProxyDaemonSetName, Namespace: ComponentNamespace}}
+		if err := r.deleteIfExists(ds); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.setImageCacheStatus(prov, imageCache); err != nil {
+		return nil, err
+	}
+	prov.Status.Endpoints = &metal3iov1alpha1.EndpointsStatus{
+		Ironic:    provisioning.IronicServiceEndpoint(ComponentNamespace),
+		Inspector: provisioning.InspectorServiceEndpoint(ComponentNamespace),
+	}
+	return deployment, nil
+}
+
+// deleteIfExists deletes obj, which is no longer needed.
+func (r *ProvisioningReconciler) deleteIfExists(obj object) error {
+	err := r.Client.Delete(context.Background(), obj)
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "unable to delete %s %s/%s", r.kindOf(obj), obj.GetNamespace(), obj.GetName())
+	}
+	r.Log.Info("deleted", "kind", r.kindOf(obj), "name", obj.GetName())
+	return nil
+}
+
+// deleteIfControlled deletes obj when it is controlled by prov, and so
+// was created by the operator rather than by another controller.
+func (r *ProvisioningReconciler) deleteIfControlled(prov *metal3iov1alpha1.Provisioning, obj object) error {
+	err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, obj)
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "unable to get %s %s/%s", r.kindOf(obj), obj.GetNamespace(), obj.GetName())
+	case !metav1.IsControlledBy(obj, prov):
+		return nil
+	}
+	return r.deleteIfExists(obj)
+}
+
+// deploymentAvailable reports whether the latest spec of deployment has
+// been rolled out and its pod is available, and why it is not.
+func deploymentAvailable(deployment *appsv1.Deployment) (bool, string) {
+	switch {
+	case deployment.Status.ObservedGeneration < deployment.Generation:
+		return false, "the deployment controller has not observed the latest spec yet"
+	case deployment.Status.UpdatedReplicas < *deployment.Spec.Replicas:
+		return false, fmt.Sprintf("%d of %d pods run the latest spec", deployment.Status.UpdatedReplicas, *deployment.Spec.Replicas)
+	case deployment.Status.AvailableReplicas < *deployment.Spec.Replicas:
+		return false, fmt.Sprintf("%d of %d pods are available", deployment.Status.AvailableReplicas, *deployment.Spec.Replicas)
+	}
+	return true, ""
+}
+
+// unschedulablePods returns why the pods of deployment and of the image
+// cache can't be scheduled, or an empty string when they all can.
+func (r *ProvisioningReconciler) unschedulablePods(deployment *appsv1.Deployment) (string, error) {
+	messages := []string{}
+	for _, labels := range []map[string]string{deployment.Spec.Selector.MatchLabels, provisioning.ImageCacheLabels} {
+		pods := &corev1.PodList{}
+		if err := r.Client.List(context.Background(), pods,
+			client.InNamespace(ComponentNamespace), client.MatchingLabels(labels)); err != nil {
+			return "", errors.Wrap(err, "unable to list the metal3 pods")
+		}
+		messages = append(messages, unschedulableMessages(pods.Items)...)
+	}
+	return strings.Join(messages, "; "), nil
+}
+
+// unschedulableMessages returns the reasons the scheduler gave for not
+// scheduling pods, one per pod it could not find a node for.
+func unschedulableMessages(pods []corev1.Pod) []string {
+	messages := []string{}
+	for _, pod := range pods {
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				messages = append(messages, fmt.Sprintf("pod %s can't be scheduled: %s", pod.Name, c.Message))
+			}
+		}
+	}
+	return messages
+}
@@ -0,0 +1,421 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+)
+
+func TestReconcileMetal3Deployment(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+			UID:  "2f0f8bdb-b5a4-4f0e-a9a7-5b7a9c1b0f11",
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}, deployment)) {
+		if assert.Len(t, deployment.OwnerReferences, 1) {
+			owner := deployment.OwnerReferences[0]
+			assert.Equal(t, "Provisioning", owner.Kind)
+			assert.Equal(t, prov.Name, owner.Name)
+			assert.True(t, *owner.Controller)
+		}
+		assert.Len(t, deployment.Spec.Template.Spec.Containers, 7)
+		assert.Equal(t, "quay.io/openshift/origin-baremetal-operator:latest", deployment.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "quay.io/openshift/origin-ironic:latest", deployment.Spec.Template.Spec.Containers[1].Image)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.DnsmasqConfigMapName}, cm)) {
+		assert.Contains(t, cm.Data["dnsmasq.conf"], "dhcp-range=172.30.20.10,172.30.20.100")
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.MariadbPasswordSecretName}
+	if assert.NoError(t, reconciler.Client.Get(ctx, secretKey, secret)) {
+		assert.NotEmpty(t, secret.Data[provisioning.MariadbPasswordSecretKey])
+	}
+
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) {
+		assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, metal3iov1alpha1.ConditionDeployed))
+		assert.True(t, meta.IsStatusConditionFalse(got.Status.Conditions, metal3iov1alpha1.ConditionReady))
+	}
+
+	co, err := reconciler.OSClient.ConfigV1().ClusterOperators().Get(ctx, clusterOperatorName, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.True(t, v1helpers.IsStatusConditionTrue(co.Status.Conditions, configv1.OperatorProgressing))
+	}
+
+	// The pod becomes available
+	deployment.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation,
+		UpdatedReplicas:    1,
+		AvailableReplicas:  1,
+	}
+	assert.NoError(t, reconciler.Client.Status().Update(ctx, deployment))
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) {
+		assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, metal3iov1alpha1.ConditionReady))
+	}
+	co, err = reconciler.OSClient.ConfigV1().ClusterOperators().Get(ctx, clusterOperatorName, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.True(t, v1helpers.IsStatusConditionTrue(co.Status.Conditions, configv1.OperatorAvailable))
+		assert.True(t, v1helpers.IsStatusConditionFalse(co.Status.Conditions, configv1.OperatorProgressing))
+	}
+
+	// The password is kept
+	unchanged := &corev1.Secret{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, secretKey, unchanged)) {
+		assert.Equal(t, secret.Data, unchanged.Data)
+	}
+}
+
+func TestReconcileMetal3DeploymentDisabled(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+
+	_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}})
+	assert.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}, deployment)) {
+		assert.Len(t, deployment.Spec.Template.Spec.Containers, 5)
+	}
+
+	err = reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.DnsmasqConfigMapName}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestReconcileMetal3DeploymentMissingImages(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	reconciler.ImagesFile = "testdata/images-missing.json"
+	ctx := context.Background()
+
+	_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}})
+	assert.Error(t, err)
+
+	err = reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}, &appsv1.Deployment{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Name: prov.Name}, got)) {
+		assert.True(t, meta.IsStatusConditionFalse(got.Status.Conditions, metal3iov1alpha1.ConditionDeployed))
+	}
+
+	co, err := reconciler.OSClient.ConfigV1().ClusterOperators().Get(ctx, clusterOperatorName, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		degraded := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorDegraded)
+		if assert.NotNil(t, degraded) {
+			assert.Equal(t, configv1.ConditionTrue, degraded.Status)
+			assert.Equal(t, string(ReasonMissingImages), degraded.Reason)
+			assert.Contains(t, degraded.Message, "image baremetalIronicInspector is missing")
+		}
+	}
+}
+
+func TestReconcileMetal3DeploymentModeSwitch(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+			ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+	deploymentKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}
+	dnsmasqKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.DnsmasqConfigMapName}
+
+	containerNames := func() []string {
+		deployment := &appsv1.Deployment{}
+		if !assert.NoError(t, reconciler.Client.Get(ctx, deploymentKey, deployment)) {
+			return nil
+		}
+		assert.Equal(t, appsv1.RecreateDeploymentStrategyType, deployment.Spec.Strategy.Type)
+		names := []string{}
+		for _, c := range deployment.Spec.Template.Spec.Containers {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Contains(t, containerNames(), "metal3-dnsmasq")
+	assert.NoError(t, reconciler.Client.Get(ctx, dnsmasqKey, &corev1.ConfigMap{}))
+
+	// Switch to an external DHCP server with the legacy flag
+	got := &metal3iov1alpha1.Provisioning{}
+	assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got))
+	got.Spec.ProvisioningNetwork = ""
+	got.Spec.ProvisioningDHCPExternal = true
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	names := containerNames()
+	assert.NotContains(t, names, "metal3-dnsmasq")
+	assert.Contains(t, names, "metal3-static-ip-manager")
+	err = reconciler.Client.Get(ctx, dnsmasqKey, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// Disable the provisioning network
+	assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got))
+	got.Spec.ProvisioningNetwork = metal3iov1alpha1.ProvisioningNetworkDisabled
+	got.Spec.ExternalIronicIP = "192.168.111.10"
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	names = containerNames()
+	assert.NotContains(t, names, "metal3-dnsmasq")
+	assert.NotContains(t, names, "metal3-static-ip-manager")
+}
+
+func TestReconcileMetal3DeploymentUnschedulable(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+			NodePlacement: &metal3iov1alpha1.NodePlacement{
+				NodeSelector: map[string]string{"example.com/provisioning": "true"},
+			},
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+
+	result, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, schedulingPollInterval, result.RequeueAfter)
+
+	deployment := &appsv1.Deployment{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}, deployment)) {
+		assert.Equal(t, map[string]string{"example.com/provisioning": "true"}, deployment.Spec.Template.Spec.NodeSelector)
+		assert.Equal(t, "system-cluster-critical", deployment.Spec.Template.Spec.PriorityClassName)
+	}
+
+	// No node carries the label
+	assert.NoError(t, reconciler.Client.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ComponentNamespace,
+			Name:      "metal3-6d8f7c9b5-x2kqp",
+			Labels:    deployment.Spec.Selector.MatchLabels,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 node(s) didn't match node selector.",
+			}},
+		},
+	}))
+	result, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, schedulingPollInterval, result.RequeueAfter)
+
+	co, err := reconciler.OSClient.ConfigV1().ClusterOperators().Get(ctx, clusterOperatorName, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		degraded := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorDegraded)
+		if assert.NotNil(t, degraded) {
+			assert.Equal(t, configv1.ConditionTrue, degraded.Status)
+			assert.Equal(t, string(ReasonUnschedulable), degraded.Reason)
+			assert.Equal(t, "pod metal3-6d8f7c9b5-x2kqp can't be scheduled: 0/3 nodes are available: 3 node(s) didn't match node selector.", degraded.Message)
+		}
+		assert.True(t, v1helpers.IsStatusConditionTrue(co.Status.Conditions, configv1.OperatorProgressing))
+	}
+}
+
+func TestUnschedulableMessages(t *testing.T) {
+	pod := func(name string, conditions ...corev1.PodCondition) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.PodStatus{Conditions: conditions},
+		}
+	}
+	pods := []corev1.Pod{
+		pod("metal3-image-cache-scheduled", corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}),
+		pod("metal3-image-cache-new"),
+		pod("metal3-image-cache-gated", corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "SchedulingGated"}),
+		pod("metal3-image-cache-tainted", corev1.PodCondition{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  corev1.PodReasonUnschedulable,
+			Message: "0/1 nodes are available: 1 node(s) had taint {example.com/edge: }, that the pod didn't tolerate.",
+		}),
+	}
+	assert.Equal(t, []string{
+		"pod metal3-image-cache-tainted can't be scheduled: 0/1 nodes are available: 1 node(s) had taint {example.com/edge: }, that the pod didn't tolerate.",
+	}, unschedulableMessages(pods))
+}
+
+func TestReconcileIronicServices(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+			ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+	ironicKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.IronicServiceName}
+	proxyKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.IronicProxyDaemonSetName}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) {
+		assert.Equal(t, &metal3iov1alpha1.EndpointsStatus{
+			Ironic:    "https://metal3-ironic.openshift-machine-api.svc:6385/v1/",
+			Inspector: "https://metal3-ironic-inspector.openshift-machine-api.svc:5050/v1/",
+		}, got.Status.Endpoints)
+	}
+	cm := &corev1.ConfigMap{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.EndpointsConfigMapName}, cm)) {
+		assert.Equal(t, got.Status.Endpoints.Ironic, cm.Data[provisioning.IronicEndpointKey])
+	}
+	endpoints := &corev1.Endpoints{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, ironicKey, endpoints)) && assert.Len(t, endpoints.Subsets, 1) {
+		assert.Equal(t, []corev1.EndpointAddress{{IP: "172.30.20.3"}}, endpoints.Subsets[0].Addresses)
+	}
+	err = reconciler.Client.Get(ctx, proxyKey, &appsv1.DaemonSet{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// Disable the provisioning network, the ironic proxy takes over
+	got.Spec.ProvisioningNetwork = metal3iov1alpha1.ProvisioningNetworkDisabled
+	got.Spec.ExternalIronicIP = "192.168.111.10"
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	assert.NoError(t, reconciler.Client.Get(ctx, proxyKey, &appsv1.DaemonSet{}))
+	service := &corev1.Service{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, ironicKey, service)) {
+		assert.Equal(t, provisioning.IronicProxyLabels, service.Spec.Selector)
+	}
+	err = reconciler.Client.Get(ctx, ironicKey, &corev1.Endpoints{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// The Endpoints the endpoints controller creates are left alone
+	assert.NoError(t, reconciler.Client.Create(ctx, &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ComponentNamespace, Name: provisioning.IronicServiceName},
+	}))
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.NoError(t, reconciler.Client.Get(ctx, ironicKey, &corev1.Endpoints{}))
+
+	// Back to a provisioning network, the proxy is removed
+	assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got))
+	got.Spec.ProvisioningNetwork = metal3iov1alpha1.ProvisioningNetworkManaged
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = reconciler.Client.Get(ctx, proxyKey, &appsv1.DaemonSet{})
+	assert.True(t, apierrors.IsNotFound(err))
+	endpoints = &corev1.Endpoints{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, ironicKey, endpoints)) && assert.Len(t, endpoints.Subsets, 1) {
+		assert.Equal(t, []corev1.EndpointAddress{{IP: "172.30.20.3"}}, endpoints.Subsets[0].Addresses)
+	}
+}
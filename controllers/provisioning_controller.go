@@ -20,20 +20,23 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	osconfigv1 "github.com/openshift/api/config/v1"
 	osclientset "github.com/openshift/client-go/config/clientset/versioned"
 	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
 )
 
 const (
-	// Remove from here when this is brought in as part of baremetal_config.go
-	baremetalProvisioningCR = "provisioning-configuration"
 	// ComponentNamespace is namespace where CBO resides
 	ComponentNamespace = "openshift-machine-api"
 	// ComponentName is the full name of CBO
@@ -49,10 +52,14 @@ type ProvisioningReconciler struct {
 	Log           logr.Logger
 	OSClient      osclientset.Interface
 	EventRecorder record.EventRecorder
+	// ImagesFile is the path of the images JSON listing the container
+	// images of the metal3 pod
+	ImagesFile string
 }
 
 // +kubebuilder:rbac:groups=metal3.io,resources=provisionings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal3.io,resources=provisionings/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *ProvisioningReconciler) isEnabled() (bool, error) {
 	ctx := context.Background()
@@ -80,11 +87,6 @@ func (r *ProvisioningReconciler) isEnabled() (bool, error) {
 func (r *ProvisioningReconciler) readProvisioningCR(req ctrl.Request) (*metal3iov1alpha1.Provisioning, error) {
 	ctx := context.Background()
 
-	// provisioning.metal3.io is a singleton
-	if req.Name != baremetalProvisioningCR {
-		r.Log.V(1).Info("ignoring invalid CR", "name", req.Name)
-		return nil, nil
-	}
 	// Fetch the Provisioning instance
 	instance := &metal3iov1alpha1.Provisioning{}
 	if err := r.Client.Get(ctx, req.NamespacedName, instance); err != nil {
@@ -118,6 +120,12 @@ func (r *ProvisioningReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error
 		return ctrl.Result{}, nil
 	}
 
+	// provisioning.metal3.io is a singleton, other objects get past the
+	// webhook when it is told not to reject them
+	if req.Name != metal3iov1alpha1.ProvisioningSingletonName {
+		return ctrl.Result{}, r.reconcileStrayProvisioning(req)
+	}
+
 	baremetalConfig, err := r.readProvisioningCR(req)
 	if err != nil {
 		// Error reading the object - requeue the request.
@@ -125,15 +133,114 @@ func (r *ProvisioningReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error
 	}
 	if baremetalConfig == nil {
 		// Provisioning configuration not available at this time.
-		// Cannot proceed wtih metal3 deployment.
+		// Cannot proceed with metal3 deployment.
 		return ctrl.Result{}, nil
 	}
-	return ctrl.Result{}, nil
+
+	// Only write the status back when reconciling changed it
+	originalStatus := baremetalConfig.Status.DeepCopy()
+
+	validationErr, err := defaultAndValidate(baremetalConfig, r.readMachineNetworks)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	setValidationStatus(baremetalConfig, validationErr)
+	if validationErr != nil {
+		r.Log.Error(validationErr, "invalid contents in Provisioning CR")
+		if err := r.updateProvisioningStatus(baremetalConfig, originalStatus); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to update Provisioning status")
+		}
+		if err := r.updateCOStatus(ReasonSyncFailed, validationErr.Error(), "Unable to apply Provisioning CR: invalid configuration"); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "unable to put %q ClusterOperator in Degraded state", clusterOperatorName)
+		}
+		// An update to the Provisioning CR triggers a new reconcile,
+		// so there is no point in requeueing an invalid configuration.
+		return ctrl.Result{}, nil
+	}
+	r.auditImmutableFieldsOverride(baremetalConfig)
+
+	// The images come from the release payload, they are read on every
+	// reconcile so that a fixed ConfigMap is picked up without a restart.
+	var deployment *appsv1.Deployment
+	images, imagesErr := provisioning.LoadImages(r.ImagesFile)
+	deployErr := imagesErr
+	if imagesErr == nil {
+		deployment, deployErr = r.ensureMetal3Deployment(baremetalConfig, images)
+	}
+	setDeploymentStatus(baremetalConfig, deployment, deployErr)
+	if err := r.updateProvisioningStatus(baremetalConfig, originalStatus); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to update Provisioning status")
+	}
+
+	if imagesErr != nil {
+		r.Log.Error(imagesErr, "invalid metal3 images", "file", r.ImagesFile)
+		if err := r.updateCOStatus(ReasonMissingImages, imagesErr.Error(), "Unable to deploy metal3: invalid images"); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "unable to put %q ClusterOperator in Degraded state", clusterOperatorName)
+		}
+		return ctrl.Result{}, imagesErr
+	}
+
+	if deployErr != nil {
+		if err := r.updateCOStatus(ReasonSyncFailed, deployErr.Error(), "Unable to deploy metal3"); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "unable to put %q ClusterOperator in Degraded state", clusterOperatorName)
+		}
+		return ctrl.Result{}, deployErr
+	}
+	if err := r.clearImmutableFieldsOverride(baremetalConfig); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Nothing else triggers a reconcile when the certificates are due
+	// for renewal, or when the image cache makes progress
+	result := ctrl.Result{RequeueAfter: tlsRenewal(baremetalConfig.Status.TLS)}
+	if imageCacheInProgress(baremetalConfig.Status.ImageCache) {
+		result.RequeueAfter = imageCachePollInterval
+	}
+
+	unschedulable, err := r.unschedulablePods(deployment)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if unschedulable != "" {
+		r.Log.Info("metal3 pods can't be scheduled", "reason", unschedulable)
+		if err := r.updateCOStatus(ReasonUnschedulable, unschedulable, "Waiting for the metal3 pods to be scheduled"); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "unable to put %q ClusterOperator in Degraded state", clusterOperatorName)
+		}
+	}
+
+	if available, msg := deploymentAvailable(deployment); !available {
+		if unschedulable == "" {
+			if err := r.updateCOStatus(ReasonSyncing, "", "Waiting for metal3 to be available: "+msg); err != nil {
+				return ctrl.Result{}, errors.Wrapf(err, "unable to update %q ClusterOperator status", clusterOperatorName)
+			}
+		}
+		// Changes to the status of the Deployment trigger a new
+		// reconcile, but not a pod the scheduler gave up on
+		if result.RequeueAfter == 0 || result.RequeueAfter > schedulingPollInterval {
+			result.RequeueAfter = schedulingPollInterval
+		}
+		return result, nil
+	}
+	if unschedulable != "" {
+		return result, nil
+	}
+
+	if err := r.updateCOStatus(ReasonComplete, "", ""); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "unable to update %q ClusterOperator status", clusterOperatorName)
+	}
+	return result, nil
 }
 
 // SetupWithManager configures the manager to run the controller
 func (r *ProvisioningReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&metal3iov1alpha1.Provisioning{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.Endpoints{}).
+		Watches(&source.Kind{Type: &osconfigv1.Proxy{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(proxyToProvisioning)}).
 		Complete(r)
 }
@@ -1,18 +1,24 @@
 package controllers
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	configv1 "github.com/openshift/api/config/v1"
 	fakeconfigclientset "github.com/openshift/client-go/config/clientset/versioned/fake"
 	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
 )
 
 func setUpSchemeForReconciler() *runtime.Scheme {
@@ -20,16 +26,21 @@ func setUpSchemeForReconciler() *runtime.Scheme {
 	// we need to add the openshift/api to the scheme to be able to read
 	// the infrastructure CR
 	configv1.Install(scheme)
+	appsv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	rbacv1.AddToScheme(scheme)
 	metal3iov1alpha1.AddToScheme(scheme)
 	return scheme
 }
 
-func newFakeProvisioningReconciler(scheme *runtime.Scheme, object runtime.Object) *ProvisioningReconciler {
+func newFakeProvisioningReconciler(scheme *runtime.Scheme, objects ...runtime.Object) *ProvisioningReconciler {
 	return &ProvisioningReconciler{
-		Client:   fakeclient.NewFakeClientWithScheme(scheme, object),
-		Log:      ctrl.Log.WithName("controllers").WithName("Provisioning"),
-		Scheme:   scheme,
-		OSClient: fakeconfigclientset.NewSimpleClientset(),
+		Client:        fakeclient.NewFakeClientWithScheme(scheme, objects...),
+		Log:           ctrl.Log.WithName("controllers").WithName("Provisioning"),
+		Scheme:        scheme,
+		OSClient:      fakeconfigclientset.NewSimpleClientset(),
+		EventRecorder: record.NewFakeRecorder(10),
+		ImagesFile:    "testdata/images.json",
 	}
 }
 
@@ -105,44 +116,26 @@ func TestProvisioningCRName(t *testing.T) {
 		name           string
 		req            ctrl.Request
 		baremetalCR    *metal3iov1alpha1.Provisioning
-		expectedError  bool
 		expectedConfig bool
 	}{
 		{
 			name: "ValidNameAndCR",
-			req:  ctrl.Request{NamespacedName: types.NamespacedName{Name: baremetalProvisioningCR, Namespace: ""}},
+			req:  ctrl.Request{NamespacedName: types.NamespacedName{Name: metal3iov1alpha1.ProvisioningSingletonName, Namespace: ""}},
 			baremetalCR: &metal3iov1alpha1.Provisioning{
 				TypeMeta: metav1.TypeMeta{
 					Kind:       "Provisioning",
 					APIVersion: "v1",
 				},
 				ObjectMeta: metav1.ObjectMeta{
-					Name: baremetalProvisioningCR,
+					Name: metal3iov1alpha1.ProvisioningSingletonName,
 				},
 			},
-			expectedError:  false,
 			expectedConfig: true,
 		},
 		{
 			name:           "MissingCR",
-			req:            ctrl.Request{NamespacedName: types.NamespacedName{Name: baremetalProvisioningCR, Namespace: ""}},
+			req:            ctrl.Request{NamespacedName: types.NamespacedName{Name: metal3iov1alpha1.ProvisioningSingletonName, Namespace: ""}},
 			baremetalCR:    &metal3iov1alpha1.Provisioning{},
-			expectedError:  false,
-			expectedConfig: false,
-		},
-		{
-			name: "InvalidName",
-			req:  ctrl.Request{NamespacedName: types.NamespacedName{Name: "invalid-name", Namespace: ""}},
-			baremetalCR: &metal3iov1alpha1.Provisioning{
-				TypeMeta: metav1.TypeMeta{
-					Kind:       "Provisioning",
-					APIVersion: "v1",
-				},
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "bad-name",
-				},
-			},
-			expectedError:  true,
 			expectedConfig: false,
 		},
 	}
@@ -152,12 +145,77 @@ func TestProvisioningCRName(t *testing.T) {
 
 			reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), tc.baremetalCR)
 			baremetalconfig, err := reconciler.readProvisioningCR(tc.req)
-			if !tc.expectedError && err != nil {
-				t.Errorf("unexpected error: %v", err)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedConfig, baremetalconfig != nil, "baremetal config results did not match")
+		})
+	}
+}
+
+func TestReconcileProvisioningConfigValidation(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		spec             metal3iov1alpha1.ProvisioningSpec
+		expectedDegraded configv1.ConditionStatus
+		expectedMessage  string
+	}{
+		{
+			name: "ValidConfig",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.11, 172.30.20.101",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedDegraded: configv1.ConditionFalse,
+		},
+		{
+			name: "InvalidCIDR",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/244",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedDegraded: configv1.ConditionTrue,
+			expectedMessage:  "provisioningNetworkCIDR",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metal3iov1alpha1.ProvisioningSingletonName,
+				},
+				Spec: tc.spec,
+			}
+			reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+
+			_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: metal3iov1alpha1.ProvisioningSingletonName}})
+			assert.NoError(t, err)
+
+			co, err := reconciler.OSClient.ConfigV1().ClusterOperators().Get(context.Background(), clusterOperatorName, metav1.GetOptions{})
+			if !assert.NoError(t, err) {
 				return
 			}
-			assert.Equal(t, tc.expectedConfig, baremetalconfig != nil, "baremetal config results did not match")
-			return
+			degraded := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorDegraded)
+			if !assert.NotNil(t, degraded) {
+				return
+			}
+			assert.Equal(t, tc.expectedDegraded, degraded.Status)
+			if tc.expectedDegraded == configv1.ConditionTrue {
+				assert.Equal(t, string(ReasonSyncFailed), degraded.Reason)
+				assert.Contains(t, degraded.Message, tc.expectedMessage)
+			}
 		})
 	}
 }
@@ -0,0 +1,168 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// setProvisioningCondition adds or replaces the condition of the same
+// type, recording the generation of the spec it was computed from.
+func setProvisioningCondition(prov *metal3iov1alpha1.Provisioning, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&prov.Status.Conditions, metav1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	// SetStatusCondition leaves ObservedGeneration alone on existing
+	// conditions
+	meta.FindStatusCondition(prov.Status.Conditions, conditionType).ObservedGeneration = prov.Generation
+}
+
+// setValidationStatus records the outcome of validating the defaulted
+// spec. The effective configuration is only updated for a valid spec,
+// so that it keeps describing what was last acted upon.
+func setValidationStatus(prov *metal3iov1alpha1.Provisioning, validationErr error) {
+	prov.Status.ObservedGeneration = prov.Generation
+
+	if validationErr != nil {
+		setProvisioningCondition(prov, metal3iov1alpha1.ConditionValid, metav1.ConditionFalse,
+			"ValidationFailed", validationErr.Error())
+		return
+	}
+
+	prov.Status.EffectiveConfig = prov.EffectiveConfig()
+	setProvisioningCondition(prov, metal3iov1alpha1.ConditionValid, metav1.ConditionTrue,
+		"ValidationSucceeded", "")
+}
+
+// setDeploymentStatus records whether the metal3 Deployment could be
+// applied, and whether it is available.
+func setDeploymentStatus(prov *metal3iov1alpha1.Provisioning, deployment *appsv1.Deployment, deployErr error) {
+	if deployErr != nil {
+		setProvisioningCondition(prov, metal3iov1alpha1.ConditionDeployed, metav1.ConditionFalse,
+			"DeployFailed", deployErr.Error())
+		return
+	}
+	setProvisioningCondition(prov, metal3iov1alpha1.ConditionDeployed, metav1.ConditionTrue,
+		"DeploySucceeded", "")
+
+	if available, msg := deploymentAvailable(deployment); !available {
+		setProvisioningCondition(prov, metal3iov1alpha1.ConditionReady, metav1.ConditionFalse,
+			"DeploymentNotAvailable", msg)
+		return
+	}
+	setProvisioningCondition(prov, metal3iov1alpha1.ConditionReady, metav1.ConditionTrue,
+		"DeploymentAvailable", "")
+}
+
+// updateProvisioningStatus writes the status of the Provisioning CR
+// when it differs from the one it was read with.
+func (r *ProvisioningReconciler) updateProvisioningStatus(prov *metal3iov1alpha1.Provisioning, original *metal3iov1alpha1.ProvisioningStatus) error {
+	if equality.Semantic.DeepEqual(original, &prov.Status) {
+		return nil
+	}
+	return r.Client.Status().Update(context.Background(), prov)
+}
+
+// auditImmutableFieldsOverride records an event and a status condition
+// whenever the break-glass annotation allowing changes to immutable
+// fields is added to, changed on or removed from the Provisioning CR.
+// It also records in status the values of the immutable fields being
+// applied, and the last change made to them along with the reason given
+// in the annotation, so that the change is still known once the
+// annotation is gone. It is only called with a valid spec.
+func (r *ProvisioningReconciler) auditImmutableFieldsOverride(prov *metal3iov1alpha1.Provisioning) {
+	reason, overridden := prov.Annotations[metal3iov1alpha1.ImmutableFieldsOverrideAnnotation]
+
+	r.recordImmutableFieldChanges(prov, reason)
+
+	existing := meta.FindStatusCondition(prov.Status.Conditions, metal3iov1alpha1.ImmutableFieldsOverridden)
+	switch {
+	case existing == nil && !overridden:
+		return
+	case existing != nil && !overridden && existing.Status == metav1.ConditionFalse:
+		return
+	case existing != nil && overridden && existing.Status == metav1.ConditionTrue && existing.Message == reason:
+		return
+	}
+
+	if overridden {
+		r.Log.Info("immutable fields override in use", "reason", reason)
+		r.EventRecorder.Eventf(prov, corev1.EventTypeWarning, "ImmutableFieldsOverridden",
+			"annotation %s allows changing immutable fields: %s", metal3iov1alpha1.ImmutableFieldsOverrideAnnotation, reason)
+		setProvisioningCondition(prov, metal3iov1alpha1.ImmutableFieldsOverridden, metav1.ConditionTrue,
+			"OverrideAnnotationSet", reason)
+		return
+	}
+
+	r.EventRecorder.Eventf(prov, corev1.EventTypeNormal, "ImmutableFieldsOverrideRemoved",
+		"annotation %s was removed", metal3iov1alpha1.ImmutableFieldsOverrideAnnotation)
+	setProvisioningCondition(prov, metal3iov1alpha1.ImmutableFieldsOverridden, metav1.ConditionFalse,
+		"OverrideAnnotationNotSet", "")
+}
+
+// recordImmutableFieldChanges compares the immutable fields with the
+// values last applied, recording an event and the last override in
+// status when any of them changed.
+func (r *ProvisioningReconciler) recordImmutableFieldChanges(prov *metal3iov1alpha1.Provisioning, reason string) {
+	status := prov.Status.ImmutableFields
+	if status == nil {
+		status = &metal3iov1alpha1.ImmutableFieldsStatus{}
+		prov.Status.ImmutableFields = status
+	}
+
+	if changes := prov.ChangedImmutableFields(status.Applied); len(changes) > 0 {
+		r.Log.Info("immutable fields changed", "reason", reason, "changes", changes)
+		r.EventRecorder.Eventf(prov, corev1.EventTypeWarning, "ImmutableFieldsChanged",
+			"immutable fields changed (%s): %s", reason, strings.Join(changes, ", "))
+		status.LastOverride = &metal3iov1alpha1.ImmutableFieldsOverride{
+			Reason:  reason,
+			Time:    metav1.Now(),
+			Changes: changes,
+		}
+	}
+	status.Applied = prov.ImmutableFieldValues()
+}
+
+// clearImmutableFieldsOverride removes the break-glass annotation once
+// the change it allowed has been applied, so that it does not keep on
+// allowing changes to immutable fields. Its removal is audited on the
+// next reconcile.
+func (r *ProvisioningReconciler) clearImmutableFieldsOverride(prov *metal3iov1alpha1.Provisioning) error {
+	if _, overridden := prov.Annotations[metal3iov1alpha1.ImmutableFieldsOverrideAnnotation]; !overridden {
+		return nil
+	}
+	patch := client.MergeFrom(prov.DeepCopy())
+	delete(prov.Annotations, metal3iov1alpha1.ImmutableFieldsOverrideAnnotation)
+	if err := r.Client.Patch(context.Background(), prov, patch); err != nil {
+		return errors.Wrapf(err, "unable to remove the %s annotation", metal3iov1alpha1.ImmutableFieldsOverrideAnnotation)
+	}
+	r.Log.Info("immutable fields override cleared")
+	return nil
+}
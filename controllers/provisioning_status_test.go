@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestReconcileProvisioningStatus(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+
+	testCases := []struct {
+		name                    string
+		spec                    metal3iov1alpha1.ProvisioningSpec
+		expectedValid           metav1.ConditionStatus
+		expectedEffectiveConfig *metal3iov1alpha1.EffectiveProvisioningConfig
+	}{
+		{
+			name: "DefaultedManaged",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+			},
+			expectedValid: metav1.ConditionTrue,
+			expectedEffectiveConfig: &metal3iov1alpha1.EffectiveProvisioningConfig{
+				ProvisioningNetwork:   metal3iov1alpha1.ProvisioningNetworkManaged,
+				ProvisioningDHCPRange: "172.30.20.10,172.30.20.100",
+			},
+		},
+		{
+			name: "CanonicalDHCPRange",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.11, 172.30.20.101",
+			},
+			expectedValid: metav1.ConditionTrue,
+			expectedEffectiveConfig: &metal3iov1alpha1.EffectiveProvisioningConfig{
+				ProvisioningNetwork:   metal3iov1alpha1.ProvisioningNetworkManaged,
+				ProvisioningDHCPRange: "172.30.20.11,172.30.20.101",
+			},
+		},
+		{
+			name: "Unmanaged",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:    "eth1",
+				ProvisioningIP:           "172.30.20.3",
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+			},
+			expectedValid: metav1.ConditionTrue,
+			expectedEffectiveConfig: &metal3iov1alpha1.EffectiveProvisioningConfig{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+			},
+		},
+		{
+			name: "Invalid",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/244",
+			},
+			expectedValid: metav1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       metal3iov1alpha1.ProvisioningSingletonName,
+					Generation: 2,
+				},
+				Spec: tc.spec,
+			}
+			reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+
+			_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}})
+			assert.NoError(t, err)
+
+			got := &metal3iov1alpha1.Provisioning{}
+			if !assert.NoError(t, reconciler.Client.Get(context.Background(), types.NamespacedName{Name: prov.Name}, got)) {
+				return
+			}
+			assert.Equal(t, int64(2), got.Status.ObservedGeneration)
+			assert.Equal(t, tc.expectedEffectiveConfig, got.Status.EffectiveConfig)
+
+			valid := meta.FindStatusCondition(got.Status.Conditions, metal3iov1alpha1.ConditionValid)
+			if assert.NotNil(t, valid) {
+				assert.Equal(t, tc.expectedValid, valid.Status)
+				assert.Equal(t, int64(2), valid.ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestAuditImmutableFieldsOverride(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+			Annotations: map[string]string{
+				metal3iov1alpha1.ImmutableFieldsOverrideAnnotation: "replacing provisioning NICs",
+			},
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), prov)
+	recorder := reconciler.EventRecorder.(*record.FakeRecorder)
+
+	reconciler.auditImmutableFieldsOverride(prov)
+	cond := meta.FindStatusCondition(prov.Status.Conditions, metal3iov1alpha1.ImmutableFieldsOverridden)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, "replacing provisioning NICs", cond.Message)
+	}
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "ImmutableFieldsOverridden")
+
+	// Nothing is recorded again while the override is unchanged
+	reconciler.auditImmutableFieldsOverride(prov)
+	assert.Len(t, recorder.Events, 0)
+
+	delete(prov.Annotations, metal3iov1alpha1.ImmutableFieldsOverrideAnnotation)
+	reconciler.auditImmutableFieldsOverride(prov)
+	cond = meta.FindStatusCondition(prov.Status.Conditions, metal3iov1alpha1.ImmutableFieldsOverridden)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	}
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "ImmutableFieldsOverrideRemoved")
+
+	reconciler.auditImmutableFieldsOverride(prov)
+	assert.Len(t, recorder.Events, 0)
+}
+
+func TestReconcileRecordsImmutableFieldsOverride(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov)
+	recorder := reconciler.EventRecorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	// The first reconcile records the values being applied
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) && assert.NotNil(t, got.Status.ImmutableFields) {
+		assert.Equal(t, "eth1", got.Status.ImmutableFields.Applied["provisioningInterface"])
+		assert.Nil(t, got.Status.ImmutableFields.LastOverride)
+	}
+	events()
+
+	got.Annotations = map[string]string{
+		metal3iov1alpha1.ImmutableFieldsOverrideAnnotation: "replacing provisioning NICs",
+	}
+	got.Spec.ProvisioningInterface = "eth2"
+	assert.NoError(t, reconciler.Client.Update(ctx, got))
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	got = &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) {
+		assert.NotContains(t, got.Annotations, metal3iov1alpha1.ImmutableFieldsOverrideAnnotation)
+		cond := meta.FindStatusCondition(got.Status.Conditions, metal3iov1alpha1.ImmutableFieldsOverridden)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		}
+		if assert.NotNil(t, got.Status.ImmutableFields) && assert.NotNil(t, got.Status.ImmutableFields.LastOverride) {
+			assert.Equal(t, "replacing provisioning NICs", got.Status.ImmutableFields.LastOverride.Reason)
+			assert.Equal(t, []string{`provisioningInterface: "eth1" -> "eth2"`}, got.Status.ImmutableFields.LastOverride.Changes)
+			assert.Equal(t, "eth2", got.Status.ImmutableFields.Applied["provisioningInterface"])
+		}
+	}
+	changed := false
+	for _, event := range events() {
+		changed = changed || strings.Contains(event, "ImmutableFieldsChanged")
+	}
+	assert.True(t, changed, "no ImmutableFieldsChanged event")
+
+	// The removal of the annotation is audited on the next reconcile,
+	// the record of the change is kept
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	got = &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, req.NamespacedName, got)) {
+		cond := meta.FindStatusCondition(got.Status.Conditions, metal3iov1alpha1.ImmutableFieldsOverridden)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		}
+		if assert.NotNil(t, got.Status.ImmutableFields) && assert.NotNil(t, got.Status.ImmutableFields.LastOverride) {
+			assert.Equal(t, "replacing provisioning NICs", got.Status.ImmutableFields.LastOverride.Reason)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// +kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=get;list;watch
+
+const (
+	// proxyName is the name of the cluster-wide Proxy config
+	proxyName = "cluster"
+
+	// trustedCABundleHashAnnotation is set on the pod template of the
+	// metal3 Deployment to the hash of the trusted CA bundle, so that the
+	// pod is restarted whenever it changes.
+	trustedCABundleHashAnnotation = "baremetal.openshift.io/trusted-ca-bundle-hash"
+)
+
+// readProxy returns the cluster-wide proxy configuration, and the hash of
+// the trusted CA bundle, which is empty until the cluster network
+// operator injected it.
+func (r *ProvisioningReconciler) readProxy() (*provisioning.Proxy, string, error) {
+	ctx := context.Background()
+
+	config := &osconfigv1.Proxy{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: proxyName}, config)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, "", errors.Wrap(err, "unable to read Proxy config")
+	}
+	proxy := proxyFromConfig(config)
+
+	cm := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: ComponentNamespace, Name: provisioning.TrustedCABundleConfigMapName}
+	err = r.Client.Get(ctx, key, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, "", errors.Wrapf(err, "unable to get ConfigMap %s/%s", key.Namespace, key.Name)
+	}
+	bundle := cm.Data[provisioning.TrustedCABundleKey]
+	if bundle == "" {
+		return proxy, "", nil
+	}
+	proxy.TrustedCABundle = true
+	hash := sha256.Sum256([]byte(bundle))
+	return proxy, hex.EncodeToString(hash[:]), nil
+}
+
+// proxyFromConfig returns the proxy configuration in the status of
+// config, which the cluster network operator only fills in once it
+// validated the spec.
+func proxyFromConfig(config *osconfigv1.Proxy) *provisioning.Proxy {
+	return &provisioning.Proxy{
+		HTTPProxy:  config.Status.HTTPProxy,
+		HTTPSProxy: config.Status.HTTPSProxy,
+		NoProxy:    config.Status.NoProxy,
+	}
+}
+
+// proxyToProvisioning maps changes to the cluster-wide Proxy config to
+// the Provisioning CR, so that the metal3 pod is rolled out with them.
+func proxyToProvisioning(obj handler.MapObject) []reconcile.Request {
+	if obj.Meta.GetName() != proxyName {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: metal3iov1alpha1.ProvisioningSingletonName}},
+	}
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// containerEnv returns the environment of the container named name.
+func containerEnv(spec corev1.PodSpec, name string) map[string]string {
+	env := map[string]string{}
+	for _, container := range spec.Containers {
+		if container.Name == name {
+			for _, v := range container.Env {
+				env[v.Name] = v.Value
+			}
+		}
+	}
+	return env
+}
+
+func TestReconcileProxy(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     configv1.InfrastructureStatus{Platform: configv1.BareMetalPlatformType},
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "172.30.20.3",
+			ProvisioningNetworkCIDR: "172.30.20.0/24",
+		},
+	}
+	proxy := &configv1.Proxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec:       configv1.ProxySpec{HTTPProxy: "http://proxy.example.com:3128"},
+		Status: configv1.ProxyStatus{
+			HTTPProxy: "http://proxy.example.com:3128",
+			NoProxy:   ".cluster.local,.svc,10.128.0.0/14",
+		},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, prov, proxy)
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: prov.Name}}
+	deploymentKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.Metal3DeploymentName}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, deploymentKey, deployment)) {
+		env := containerEnv(deployment.Spec.Template.Spec, "metal3-ironic")
+		assert.Equal(t, "http://proxy.example.com:3128", env["HTTP_PROXY"])
+		assert.NotContains(t, env, "HTTPS_PROXY")
+		noProxy := strings.Split(env["NO_PROXY"], ",")
+		for _, entry := range []string{".svc", "172.30.20.0/24", "172.30.20.3", "metal3-ironic.openshift-machine-api.svc"} {
+			assert.Contains(t, noProxy, entry)
+		}
+		assert.NotContains(t, deployment.Spec.Template.Annotations, trustedCABundleHashAnnotation)
+	}
+
+	// The cluster network operator injects the trusted CA bundle
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.TrustedCABundleConfigMapName}
+	if !assert.NoError(t, reconciler.Client.Get(ctx, cmKey, cm)) {
+		return
+	}
+	assert.Equal(t, "true", cm.Labels["config.openshift.io/inject-trusted-cabundle"])
+	cm.Data = map[string]string{provisioning.TrustedCABundleKey: "-----BEGIN CERTIFICATE-----\n"}
+	assert.NoError(t, reconciler.Client.Update(ctx, cm))
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	injected := &corev1.ConfigMap{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, cmKey, injected)) {
+		assert.Equal(t, cm.Data, injected.Data)
+	}
+	deployment = &appsv1.Deployment{}
+	if assert.NoError(t, reconciler.Client.Get(ctx, deploymentKey, deployment)) {
+		assert.NotEmpty(t, deployment.Spec.Template.Annotations[trustedCABundleHashAnnotation])
+		mounted := false
+		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+			if volume.ConfigMap != nil && volume.ConfigMap.Name == provisioning.TrustedCABundleConfigMapName {
+				mounted = true
+			}
+		}
+		assert.True(t, mounted)
+	}
+}
+
+func TestProxyToProvisioning(t *testing.T) {
+	proxy := &configv1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	assert.Equal(t, []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Name: metal3iov1alpha1.ProvisioningSingletonName}},
+	}, proxyToProvisioning(handler.MapObject{Meta: proxy, Object: proxy}))
+
+	other := &configv1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	assert.Empty(t, proxyToProvisioning(handler.MapObject{Meta: other, Object: other}))
+}
@@ -0,0 +1,192 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	"sigs.k8s.io/yaml"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	metal3iov1beta1 "github.com/openshift/cluster-baremetal-operator/api/v1beta1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+// defaultAndValidate applies the same defaults as the mutating webhook,
+// in case the CR was created or updated while the webhook was not
+// available, then validates prov. readMachineNetworks is only called
+// when the provisioning network is disabled, to check the external IPs
+// against the machine networks. validationErr reports an invalid spec,
+// err a failure to read the machine networks.
+func defaultAndValidate(prov *metal3iov1alpha1.Provisioning, readMachineNetworks func() ([]*net.IPNet, []string, error)) (validationErr error, err error) {
+	prov.SetDefaults()
+
+	if validationErr = prov.ValidateBaremetalProvisioningConfig(); validationErr != nil {
+		return validationErr, nil
+	}
+	if prov.NetworkMode() != metal3iov1alpha1.ProvisioningNetworkDisabled {
+		return nil, nil
+	}
+	machineNetworks, reservedIPs, err := readMachineNetworks()
+	if err != nil {
+		return nil, err
+	}
+	return prov.ValidateExternalIPsInMachineNetworks(machineNetworks, reservedIPs), nil
+}
+
+// renderScheme knows the kinds read and written by Render.
+func renderScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		appsv1.AddToScheme,
+		corev1.AddToScheme,
+		rbacv1.AddToScheme,
+		osconfigv1.Install,
+		metal3iov1alpha1.AddToScheme,
+		metal3iov1beta1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			panic(err)
+		}
+	}
+	return scheme
+}
+
+// Render writes to outDir, as YAML, the defaulted Provisioning CR read
+// from provisioningFile and the manifests provisioning.Manifests
+// returns for it, without a cluster. It goes through the same
+// defaulting and validation as the reconciler, with the Infrastructure
+// config read from infrastructureFile, and the Proxy config read from
+// proxyFile unless it is empty. The machine networks are not known
+// offline, so the external IPs are only checked against the IPs of the
+// Infrastructure config, and the trusted CA bundle is not mounted.
+//
+// What the reconciler adds when applying the manifests depends on the
+// cluster, so it is left out: owner references, the spec-hash
+// annotation, the credentials and trusted CA bundle hash annotations
+// of the metal3 pod template, the credentials and TLS Secrets, and the
+// ironic CA bundle ConfigMap.
+func Render(provisioningFile, imagesFile, infrastructureFile, proxyFile, outDir string) error {
+	scheme := renderScheme()
+
+	prov := &metal3iov1alpha1.Provisioning{}
+	if err := readObject(scheme, provisioningFile, prov); err != nil {
+		return err
+	}
+	infra := &osconfigv1.Infrastructure{}
+	if err := readObject(scheme, infrastructureFile, infra); err != nil {
+		return err
+	}
+	images, err := provisioning.LoadImages(imagesFile)
+	if err != nil {
+		return err
+	}
+
+	if infra.Status.Platform != osconfigv1.BareMetalPlatformType {
+		return fmt.Errorf("platform %q is not %s, the operator is disabled", infra.Status.Platform, osconfigv1.BareMetalPlatformType)
+	}
+	if prov.Name != metal3iov1alpha1.ProvisioningSingletonName {
+		return fmt.Errorf("only the Provisioning named %q is used, not %q", metal3iov1alpha1.ProvisioningSingletonName, prov.Name)
+	}
+
+	validationErr, err := defaultAndValidate(prov, func() ([]*net.IPNet, []string, error) {
+		return nil, infrastructureIPs(infra), nil
+	})
+	if err != nil {
+		return err
+	}
+	if validationErr != nil {
+		return errors.Wrap(validationErr, "invalid Provisioning")
+	}
+
+	proxy := &provisioning.Proxy{}
+	if proxyFile != "" {
+		config := &osconfigv1.Proxy{}
+		if err := readObject(scheme, proxyFile, config); err != nil {
+			return err
+		}
+		proxy = proxyFromConfig(config)
+	}
+
+	objects, err := provisioning.Manifests(prov, images, proxy, ComponentNamespace)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return errors.Wrapf(err, "unable to create %s", outDir)
+	}
+	for _, obj := range append([]runtime.Object{prov}, objects...) {
+		if err := writeObject(scheme, outDir, obj.(object)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readObject decodes the YAML or JSON manifest at path into into. A
+// manifest of another version of the same kind is converted.
+func readObject(scheme *runtime.Scheme, path string, into runtime.Object) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", path)
+	}
+	decoded, _, err := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "unable to decode %s", path)
+	}
+	if convertible, ok := decoded.(conversion.Convertible); ok {
+		if hub, ok := into.(conversion.Hub); ok {
+			return errors.Wrapf(convertible.ConvertTo(hub), "unable to convert %s", path)
+		}
+	}
+	if reflect.TypeOf(decoded) != reflect.TypeOf(into) {
+		return fmt.Errorf("%s holds a %s", path, decoded.GetObjectKind().GroupVersionKind().Kind)
+	}
+	reflect.ValueOf(into).Elem().Set(reflect.ValueOf(decoded).Elem())
+	return nil
+}
+
+// writeObject writes obj to a file named after its kind and name.
+func writeObject(scheme *runtime.Scheme, outDir string, obj object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode %s %s", gvk.Kind, obj.GetName())
+	}
+	path := filepath.Join(outDir, fmt.Sprintf("%s-%s.yaml", strings.ToLower(gvk.Kind), obj.GetName()))
+	return errors.Wrapf(ioutil.WriteFile(path, data, 0644), "unable to write %s", path)
+}
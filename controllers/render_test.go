@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+const renderInfrastructure = `apiVersion: config.openshift.io/v1
+kind: Infrastructure
+metadata:
+  name: cluster
+status:
+  platform: BareMetal
+  platformStatus:
+    type: BareMetal
+    baremetal:
+      apiServerInternalIP: 192.168.111.5
+      ingressIP: 192.168.111.4
+`
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		name          string
+		provisioning  string
+		infra         string
+		proxy         string
+		expectedFiles []string
+		expectedEnv   string
+		expectedError string
+	}{
+		{
+			name: "Managed",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningInterface: eth1
+  provisioningIP: 172.30.20.3
+  provisioningNetworkCIDR: 172.30.20.0/24
+  provisioningNetwork: Managed
+`,
+			infra: renderInfrastructure,
+			expectedFiles: []string{
+				"configmap-metal3-dnsmasq-config.yaml",
+				"configmap-metal3-ironic-endpoints.yaml",
+				"configmap-metal3-trusted-ca-bundle.yaml",
+				"deployment-metal3.yaml",
+				"endpoints-metal3-ironic-inspector.yaml",
+				"endpoints-metal3-ironic.yaml",
+				"provisioning-provisioning-configuration.yaml",
+				"service-metal3-ironic-inspector.yaml",
+				"service-metal3-ironic.yaml",
+			},
+		},
+		{
+			name: "DisabledV1beta1",
+			provisioning: `apiVersion: metal3.io/v1beta1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.10
+`,
+			infra: renderInfrastructure,
+			expectedFiles: []string{
+				"configmap-metal3-ironic-endpoints.yaml",
+				"configmap-metal3-trusted-ca-bundle.yaml",
+				"daemonset-metal3-ironic-proxy.yaml",
+				"deployment-metal3.yaml",
+				"endpoints-metal3-ironic-inspector.yaml",
+				"provisioning-provisioning-configuration.yaml",
+				"service-metal3-ironic-inspector.yaml",
+				"service-metal3-ironic.yaml",
+			},
+		},
+		{
+			name: "ImageCache",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.10
+  provisioningOSDownloadURL: https://releases.example.com/rhcos/rhcos.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7
+`,
+			infra: renderInfrastructure,
+			expectedFiles: []string{
+				"configmap-metal3-ironic-endpoints.yaml",
+				"configmap-metal3-trusted-ca-bundle.yaml",
+				"daemonset-metal3-image-cache.yaml",
+				"daemonset-metal3-ironic-proxy.yaml",
+				"deployment-metal3.yaml",
+				"endpoints-metal3-ironic-inspector.yaml",
+				"provisioning-provisioning-configuration.yaml",
+				"role-metal3-image-cache.yaml",
+				"rolebinding-metal3-image-cache.yaml",
+				"service-metal3-ironic-inspector.yaml",
+				"service-metal3-ironic.yaml",
+				"serviceaccount-metal3-image-cache.yaml",
+			},
+		},
+		{
+			name: "Proxy",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.10
+`,
+			infra: renderInfrastructure,
+			proxy: `apiVersion: config.openshift.io/v1
+kind: Proxy
+metadata:
+  name: cluster
+spec:
+  httpsProxy: http://proxy.example.com:3128
+status:
+  httpsProxy: http://proxy.example.com:3128
+  noProxy: .cluster.local,.svc,10.128.0.0/14,127.0.0.1,localhost
+`,
+			expectedFiles: []string{
+				"configmap-metal3-ironic-endpoints.yaml",
+				"configmap-metal3-trusted-ca-bundle.yaml",
+				"daemonset-metal3-ironic-proxy.yaml",
+				"deployment-metal3.yaml",
+				"endpoints-metal3-ironic-inspector.yaml",
+				"provisioning-provisioning-configuration.yaml",
+				"service-metal3-ironic-inspector.yaml",
+				"service-metal3-ironic.yaml",
+			},
+			expectedEnv: `- name: HTTPS_PROXY
+          value: http://proxy.example.com:3128
+        - name: NO_PROXY
+          value: .cluster.local,.svc,10.128.0.0/14,127.0.0.1,localhost,192.168.111.10,`,
+		},
+		{
+			name: "InvalidOSDownloadURL",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.10
+  provisioningOSDownloadURL: https://releases.example.com/rhcos/rhcos.qcow2.gz
+`,
+			infra:         renderInfrastructure,
+			expectedError: "should carry the sha256 of the image in its query",
+		},
+		{
+			name: "Invalid",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.5
+`,
+			infra:         renderInfrastructure,
+			expectedError: "externalIronicIP \"192.168.111.5\" is already used by the cluster",
+		},
+		{
+			name: "NotSingleton",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-sample
+spec:
+  provisioningNetwork: Disabled
+  externalIronicIP: 192.168.111.10
+`,
+			infra:         renderInfrastructure,
+			expectedError: "only the Provisioning named \"provisioning-configuration\" is used",
+		},
+		{
+			name: "OtherPlatform",
+			provisioning: `apiVersion: metal3.io/v1alpha1
+kind: Provisioning
+metadata:
+  name: provisioning-configuration
+`,
+			infra: `apiVersion: config.openshift.io/v1
+kind: Infrastructure
+metadata:
+  name: cluster
+status:
+  platform: AWS
+`,
+			expectedError: "platform \"AWS\" is not BareMetal",
+		},
+		{
+			name:          "WrongKind",
+			provisioning:  renderInfrastructure,
+			infra:         renderInfrastructure,
+			expectedError: "holds a Infrastructure",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "render")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			outDir := filepath.Join(dir, "out")
+
+			proxyFile := ""
+			if tc.proxy != "" {
+				proxyFile = writeTestFile(t, dir, "proxy.yaml", tc.proxy)
+			}
+			err = Render(
+				writeTestFile(t, dir, "provisioning.yaml", tc.provisioning),
+				"testdata/images.json",
+				writeTestFile(t, dir, "infrastructure.yaml", tc.infra),
+				proxyFile,
+				outDir)
+			if tc.expectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			files, err := ioutil.ReadDir(outDir)
+			if assert.NoError(t, err) {
+				names := []string{}
+				for _, f := range files {
+					names = append(names, f.Name())
+				}
+				assert.Equal(t, tc.expectedFiles, names)
+			}
+			if tc.expectedEnv != "" {
+				deployment, err := ioutil.ReadFile(filepath.Join(outDir, "deployment-metal3.yaml"))
+				assert.NoError(t, err)
+				assert.Contains(t, string(deployment), tc.expectedEnv)
+			}
+		})
+	}
+}
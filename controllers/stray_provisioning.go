@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// strayProvisionings counts the Provisioning objects which are ignored
+// because they are not named ProvisioningSingletonName.
+var strayProvisionings = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "cluster_baremetal_operator_stray_provisionings",
+	Help: fmt.Sprintf("Number of Provisioning objects ignored because they are not named %s.", metal3iov1alpha1.ProvisioningSingletonName),
+})
+
+func init() {
+	metrics.Registry.MustRegister(strayProvisionings)
+}
+
+// reconcileStrayProvisioning tells the owner of a Provisioning object
+// which is not the singleton that it is ignored, and keeps count of such
+// objects.
+func (r *ProvisioningReconciler) reconcileStrayProvisioning(req ctrl.Request) error {
+	ctx := context.Background()
+
+	if err := r.countStrayProvisionings(); err != nil {
+		return err
+	}
+
+	prov := &metal3iov1alpha1.Provisioning{}
+	if err := r.Client.Get(ctx, req.NamespacedName, prov); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to read Provisioning %q", req.Name)
+	}
+
+	if meta.IsStatusConditionTrue(prov.Status.Conditions, metal3iov1alpha1.ConditionIgnored) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("only the Provisioning named %q is used, this one is ignored", metal3iov1alpha1.ProvisioningSingletonName)
+	r.Log.Info("ignoring Provisioning which is not the singleton", "name", prov.Name)
+	r.EventRecorder.Event(prov, corev1.EventTypeWarning, "Ignored", msg)
+	setProvisioningCondition(prov, metal3iov1alpha1.ConditionIgnored, metav1.ConditionTrue, "NotSingleton", msg)
+	return r.Client.Status().Update(ctx, prov)
+}
+
+// countStrayProvisionings updates the strayProvisionings metric.
+func (r *ProvisioningReconciler) countStrayProvisionings() error {
+	provs := &metal3iov1alpha1.ProvisioningList{}
+	if err := r.Client.List(context.Background(), provs); err != nil {
+		return errors.Wrap(err, "unable to list Provisioning objects")
+	}
+
+	stray := 0
+	for _, prov := range provs.Items {
+		if prov.Name != metal3iov1alpha1.ProvisioningSingletonName {
+			stray++
+		}
+	}
+	strayProvisionings.Set(float64(stray))
+	return nil
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	m := &dto.Metric{}
+	assert.NoError(t, gauge.Write(m))
+	return m.GetGauge().GetValue()
+}
+
+func TestReconcileStrayProvisioning(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			Platform: configv1.BareMetalPlatformType,
+		},
+	}
+	singleton := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: metal3iov1alpha1.ProvisioningSingletonName},
+	}
+	stray := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{Name: "provisioning-sample"},
+	}
+	reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler(), infra, singleton, stray)
+	recorder := reconciler.EventRecorder.(*record.FakeRecorder)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: stray.Name}}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), gaugeValue(t, strayProvisionings))
+
+	got := &metal3iov1alpha1.Provisioning{}
+	if assert.NoError(t, reconciler.Client.Get(context.Background(), req.NamespacedName, got)) {
+		cond := meta.FindStatusCondition(got.Status.Conditions, metal3iov1alpha1.ConditionIgnored)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, metav1.ConditionTrue, cond.Status)
+			assert.Contains(t, cond.Message, metal3iov1alpha1.ProvisioningSingletonName)
+		}
+	}
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, "Warning Ignored")
+	}
+
+	// The event is only emitted once
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Len(t, recorder.Events, 0)
+
+	assert.NoError(t, reconciler.Client.Delete(context.Background(), got))
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), gaugeValue(t, strayProvisionings))
+}
@@ -0,0 +1,231 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+const (
+	caValidity    = 10 * 365 * 24 * time.Hour
+	caRenewBefore = 365 * 24 * time.Hour
+
+	servingValidity    = 365 * 24 * time.Hour
+	servingRenewBefore = 90 * 24 * time.Hour
+)
+
+// certificateExpiry exposes when the certificates securing the ironic
+// and ironic-inspector APIs expire.
+var certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cluster_baremetal_operator_certificate_expiry_timestamp_seconds",
+	Help: "Expiry time of the certificates securing the ironic and ironic-inspector APIs, in seconds since the epoch.",
+}, []string{"certificate"})
+
+func init() {
+	metrics.Registry.MustRegister(certificateExpiry)
+}
+
+// ensureTLS issues the CA and the serving certificate of the ironic and
+// ironic-inspector APIs and publishes the CA bundle of their clients.
+// The CA is renewed a year before it expires, and the clients keep
+// trusting the one it replaced until that one expires. The serving
+// certificate is re-issued 90 days before it expires, or as soon as the
+// addresses of the APIs or the CA change.
+func (r *ProvisioningReconciler) ensureTLS(prov *metal3iov1alpha1.Provisioning) error {
+	now := time.Now()
+
+	ca, bundle, err := r.ensureCA(prov, now)
+	if err != nil {
+		return err
+	}
+	caCert, err := provisioning.ParseCertificate(ca.Cert)
+	if err != nil {
+		return err
+	}
+	servingCert, err := r.ensureServingCertificate(prov, ca, caCert, now)
+	if err != nil {
+		return err
+	}
+
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: provisioning.CABundleConfigMapName, Namespace: ComponentNamespace},
+		Data:       map[string]string{provisioning.CABundleKey: string(bundle)},
+	}
+	if err := r.apply(prov, desired, desired.DeepCopy()); err != nil {
+		return err
+	}
+
+	prov.Status.TLS = &metal3iov1alpha1.TLSStatus{
+		CAExpiry:          &metav1.Time{Time: caCert.NotAfter},
+		CertificateExpiry: &metav1.Time{Time: servingCert.NotAfter},
+	}
+	certificateExpiry.WithLabelValues("ca").Set(float64(caCert.NotAfter.Unix()))
+	certificateExpiry.WithLabelValues("serving").Set(float64(servingCert.NotAfter.Unix()))
+	return nil
+}
+
+// ensureCA returns the CA, which is generated when missing or about to
+// expire, and the bundle of the CA certificates clients trust.
+func (r *ProvisioningReconciler) ensureCA(prov *metal3iov1alpha1.Provisioning, now time.Time) (*provisioning.KeyPair, []byte, error) {
+	secret, err := r.getSecret(provisioning.CASecretName)
+	if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+		return nil, nil, err
+	}
+
+	var previous []byte
+	if err == nil {
+		ca := &provisioning.KeyPair{Cert: secret.Data[corev1.TLSCertKey], Key: secret.Data[corev1.TLSPrivateKeyKey]}
+		cert, parseErr := provisioning.ParseCertificate(ca.Cert)
+		if parseErr == nil && now.Add(caRenewBefore).Before(cert.NotAfter) {
+			bundle := ca.Cert
+			if old, err := provisioning.ParseCertificate(secret.Data[provisioning.PreviousCACertKey]); err == nil && now.Before(old.NotAfter) {
+				bundle = append(append([]byte{}, bundle...), secret.Data[provisioning.PreviousCACertKey]...)
+			}
+			return ca, bundle, nil
+		}
+		if parseErr == nil && now.Before(cert.NotAfter) {
+			previous = ca.Cert
+		}
+	}
+
+	ca, err := provisioning.NewCA(now, caValidity)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate CA")
+	}
+	data := map[string][]byte{
+		corev1.TLSCertKey:       ca.Cert,
+		corev1.TLSPrivateKeyKey: ca.Key,
+	}
+	if previous != nil {
+		data[provisioning.PreviousCACertKey] = previous
+	}
+	if err := r.writeTLSSecret(provisioning.CASecretName, secret, data); err != nil {
+		return nil, nil, err
+	}
+	r.EventRecorder.Event(prov, corev1.EventTypeNormal, "CAGenerated",
+		"generated the CA of the ironic and ironic-inspector APIs")
+	return ca, append(append([]byte{}, ca.Cert...), previous...), nil
+}
+
+// ensureServingCertificate returns the serving certificate of the APIs,
+// which is issued by ca when missing, about to expire, issued by another
+// CA or not valid for all the addresses of the APIs.
+func (r *ProvisioningReconciler) ensureServingCertificate(prov *metal3iov1alpha1.Provisioning, ca *provisioning.KeyPair, caCert *x509.Certificate, now time.Time) (*x509.Certificate, error) {
+	hosts := provisioning.ServingHosts(prov, ComponentNamespace)
+
+	secret, err := r.getSecret(provisioning.TLSSecretName)
+	if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+		return nil, err
+	}
+	reason := "missing"
+	if err == nil {
+		reason = servingCertificateOutdated(secret.Data[corev1.TLSCertKey], caCert, hosts, now)
+		if reason == "" {
+			return provisioning.ParseCertificate(secret.Data[corev1.TLSCertKey])
+		}
+	}
+
+	serving, err := ca.Issue(hosts, now, servingValidity)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to issue serving certificate")
+	}
+	if err := r.writeTLSSecret(provisioning.TLSSecretName, secret, map[string][]byte{
+		corev1.TLSCertKey:       append(append([]byte{}, serving.Cert...), ca.Cert...),
+		corev1.TLSPrivateKeyKey: serving.Key,
+	}); err != nil {
+		return nil, err
+	}
+	r.Log.Info("issued serving certificate", "reason", reason)
+	r.EventRecorder.Eventf(prov, corev1.EventTypeNormal, "CertificateIssued",
+		"issued the serving certificate of the ironic and ironic-inspector APIs, the previous one was %s", reason)
+	return provisioning.ParseCertificate(serving.Cert)
+}
+
+// servingCertificateOutdated returns why the PEM encoded serving
+// certificate data must be re-issued, or an empty string.
+func servingCertificateOutdated(data []byte, caCert *x509.Certificate, hosts []string, now time.Time) string {
+	cert, err := provisioning.ParseCertificate(data)
+	switch {
+	case err != nil:
+		return "invalid"
+	case cert.CheckSignatureFrom(caCert) != nil:
+		return "issued by another CA"
+	case now.Add(servingRenewBefore).After(cert.NotAfter):
+		return "about to expire"
+	}
+
+	certHosts := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		certHosts = append(certHosts, ip.String())
+	}
+	expected := append([]string{}, hosts...)
+	sort.Strings(certHosts)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(certHosts, expected) {
+		return "issued for other addresses"
+	}
+	return ""
+}
+
+// writeTLSSecret creates the TLS Secret called name with data, or
+// updates existing when it was read.
+func (r *ProvisioningReconciler) writeTLSSecret(name string, existing *corev1.Secret, data map[string][]byte) error {
+	if existing != nil {
+		existing.Data = data
+		return r.updateSecret(existing)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ComponentNamespace, Name: name},
+		Type:       corev1.SecretTypeTLS,
+		Data:       data,
+	}
+	if err := r.Client.Create(context.Background(), secret, fieldOwner); err != nil {
+		return errors.Wrapf(err, "unable to create Secret %s/%s", ComponentNamespace, name)
+	}
+	r.Log.Info("generated certificate", "secret", name)
+	return nil
+}
+
+// tlsRenewal returns how long until a certificate in status must be
+// re-issued, so that the Provisioning CR is reconciled by then.
+func tlsRenewal(status *metal3iov1alpha1.TLSStatus) time.Duration {
+	if status == nil || status.CAExpiry == nil || status.CertificateExpiry == nil {
+		return 0
+	}
+	renewal := status.CertificateExpiry.Add(-servingRenewBefore)
+	if caRenewal := status.CAExpiry.Add(-caRenewBefore); caRenewal.Before(renewal) {
+		renewal = caRenewal
+	}
+	if until := time.Until(renewal); until > time.Minute {
+		return until
+	}
+	return time.Minute
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	"github.com/openshift/cluster-baremetal-operator/provisioning"
+)
+
+func TestEnsureTLS(t *testing.T) {
+	now := time.Now()
+	newCA := func(validity time.Duration) *provisioning.KeyPair {
+		ca, err := provisioning.NewCA(now.Add(-time.Hour), validity)
+		assert.NoError(t, err)
+		return ca
+	}
+	tlsSecret := func(name string, pair *provisioning.KeyPair) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ComponentNamespace, Name: name},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: pair.Cert, corev1.TLSPrivateKeyKey: pair.Key},
+		}
+	}
+	prov := &metal3iov1alpha1.Provisioning{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metal3iov1alpha1.ProvisioningSingletonName,
+			UID:  "2f0f8bdb-b5a4-4f0e-a9a7-5b7a9c1b0f11",
+		},
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkManaged,
+			ProvisioningIP:      "172.30.20.3",
+		},
+	}
+	hosts := provisioning.ServingHosts(prov, ComponentNamespace)
+
+	validCA := newCA(caValidity)
+	expiringServing, err := validCA.Issue(hosts, now.Add(-time.Hour), 24*time.Hour)
+	assert.NoError(t, err)
+	otherServing, err := newCA(caValidity).Issue(hosts, now.Add(-time.Hour), servingValidity)
+	assert.NoError(t, err)
+	expiringCA := newCA(30 * 24 * time.Hour)
+
+	testCases := []struct {
+		name             string
+		objects          []*corev1.Secret
+		expectedCA       *provisioning.KeyPair
+		expectedPrevious *provisioning.KeyPair
+		expectedIssued   bool
+	}{
+		{
+			name:           "Missing",
+			expectedIssued: true,
+		},
+		{
+			name:           "ServingCertificateMissing",
+			objects:        []*corev1.Secret{tlsSecret(provisioning.CASecretName, validCA)},
+			expectedCA:     validCA,
+			expectedIssued: true,
+		},
+		{
+			name: "ServingCertificateExpiring",
+			objects: []*corev1.Secret{
+				tlsSecret(provisioning.CASecretName, validCA),
+				tlsSecret(provisioning.TLSSecretName, expiringServing),
+			},
+			expectedCA:     validCA,
+			expectedIssued: true,
+		},
+		{
+			name: "ServingCertificateFromOtherCA",
+			objects: []*corev1.Secret{
+				tlsSecret(provisioning.CASecretName, validCA),
+				tlsSecret(provisioning.TLSSecretName, otherServing),
+			},
+			expectedCA:     validCA,
+			expectedIssued: true,
+		},
+		{
+			name:             "CAExpiring",
+			objects:          []*corev1.Secret{tlsSecret(provisioning.CASecretName, expiringCA)},
+			expectedPrevious: expiringCA,
+			expectedIssued:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reconciler := newFakeProvisioningReconciler(setUpSchemeForReconciler())
+			ctx := context.Background()
+			for _, secret := range tc.objects {
+				assert.NoError(t, reconciler.Client.Create(ctx, secret.DeepCopy()))
+			}
+			getSecret := func(name string) *corev1.Secret {
+				secret := &corev1.Secret{}
+				assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: name}, secret))
+				return secret
+			}
+			var original []byte
+			for _, secret := range tc.objects {
+				if secret.Name == provisioning.TLSSecretName {
+					original = secret.Data[corev1.TLSCertKey]
+				}
+			}
+
+			p := prov.DeepCopy()
+			if !assert.NoError(t, reconciler.ensureTLS(p)) {
+				return
+			}
+
+			ca := getSecret(provisioning.CASecretName)
+			if tc.expectedCA != nil {
+				assert.Equal(t, tc.expectedCA.Cert, ca.Data[corev1.TLSCertKey])
+			}
+			serving := getSecret(provisioning.TLSSecretName)
+			assert.Equal(t, corev1.SecretTypeTLS, serving.Type)
+			assert.Equal(t, tc.expectedIssued, string(original) != string(serving.Data[corev1.TLSCertKey]))
+
+			bundle := &corev1.ConfigMap{}
+			if !assert.NoError(t, reconciler.Client.Get(ctx, types.NamespacedName{Namespace: ComponentNamespace, Name: provisioning.CABundleConfigMapName}, bundle)) {
+				return
+			}
+			roots := x509.NewCertPool()
+			assert.True(t, roots.AppendCertsFromPEM([]byte(bundle.Data[provisioning.CABundleKey])))
+			if tc.expectedPrevious != nil {
+				assert.Contains(t, bundle.Data[provisioning.CABundleKey], string(tc.expectedPrevious.Cert))
+				assert.Equal(t, tc.expectedPrevious.Cert, ca.Data[provisioning.PreviousCACertKey])
+			}
+
+			cert, err := provisioning.ParseCertificate(serving.Data[corev1.TLSCertKey])
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, host := range []string{"172.30.20.3", "metal3-ironic.openshift-machine-api.svc"} {
+				_, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+				assert.NoError(t, err, host)
+			}
+			if assert.NotNil(t, p.Status.TLS) {
+				assert.True(t, p.Status.TLS.CertificateExpiry.Time.Equal(cert.NotAfter))
+			}
+
+			// Nothing is issued while the certificates are valid
+			assert.NoError(t, reconciler.ensureTLS(p))
+			assert.Equal(t, ca.Data, getSecret(provisioning.CASecretName).Data)
+			assert.Equal(t, serving.Data, getSecret(provisioning.TLSSecretName).Data)
+
+			// The addresses of the APIs changed
+			p.Spec.ProvisioningIP = "172.30.20.4"
+			assert.NoError(t, reconciler.ensureTLS(p))
+			assert.Equal(t, ca.Data, getSecret(provisioning.CASecretName).Data)
+			cert, err = provisioning.ParseCertificate(getSecret(provisioning.TLSSecretName).Data[corev1.TLSCertKey])
+			if assert.NoError(t, err) {
+				_, err = cert.Verify(x509.VerifyOptions{DNSName: "172.30.20.4", Roots: roots})
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTLSRenewal(t *testing.T) {
+	at := func(d time.Duration) *metav1.Time {
+		return &metav1.Time{Time: time.Now().Add(d)}
+	}
+	day := 24 * time.Hour
+
+	assert.Equal(t, time.Duration(0), tlsRenewal(nil))
+	assert.InDelta(t, float64(10*day), float64(tlsRenewal(&metal3iov1alpha1.TLSStatus{
+		CAExpiry:          at(5 * 365 * day),
+		CertificateExpiry: at(100 * day),
+	})), float64(time.Minute))
+	assert.InDelta(t, float64(5*day), float64(tlsRenewal(&metal3iov1alpha1.TLSStatus{
+		CAExpiry:          at(370 * day),
+		CertificateExpiry: at(300 * day),
+	})), float64(time.Minute))
+	assert.Equal(t, time.Minute, tlsRenewal(&metal3iov1alpha1.TLSStatus{
+		CAExpiry:          at(5 * 365 * day),
+		CertificateExpiry: at(day),
+	}))
+}
@@ -0,0 +1,277 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package downloader fetches the OS image into the image cache of a
+// node, verifying it against the sha256 carried by its URL.
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// DefaultBackoff is how long to wait between failed attempts: from 10
+// seconds up to 5 minutes over 8 attempts, about 15 minutes overall.
+var DefaultBackoff = wait.Backoff{
+	Duration: 10 * time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    8,
+	Cap:      5 * time.Minute,
+}
+
+// DefaultIdleTimeout is how long an attempt waits for data from the
+// server before giving up on it.
+const DefaultIdleTimeout = time.Minute
+
+// defaultClient gives up on servers it can't connect to, rather than
+// waiting for the TCP timeouts of the node. Servers that stop sending
+// data are dealt with by the IdleTimeout of each attempt.
+var defaultClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// Progress is the state of a download on a node.
+type Progress struct {
+	// Phase is one of Downloading, Verifying, Downloaded or Failed
+	Phase metal3iov1alpha1.ImageCachePhase `json:"phase"`
+	// Attempt counts the downloads, starting at 1
+	Attempt int `json:"attempt,omitempty"`
+	// DownloadedBytes is how much of the image was received
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	// TotalBytes is the size of the image, 0 when the server did not
+	// tell it
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// SHA256 is the checksum the image was verified against, once it
+	// is Downloaded
+	SHA256 string `json:"sha256,omitempty"`
+	// Message tells why the last attempt failed
+	Message string `json:"message,omitempty"`
+}
+
+// Options configure Download.
+type Options struct {
+	// URL is where the image is downloaded from
+	URL string
+	// SHA256 is the hex encoded checksum the image must match
+	SHA256 string
+	// Dir is where the image is written, under the last element of
+	// the path of URL
+	Dir string
+	// Backoff sets the number of attempts and the wait between them
+	Backoff wait.Backoff
+	// Client defaults to one with connection and TLS handshake timeouts
+	Client *http.Client
+	// IdleTimeout aborts an attempt that receives no data for that long,
+	// defaults to DefaultIdleTimeout
+	IdleTimeout time.Duration
+	// Report is called with the progress of the download, at most once
+	// per ReportInterval while the image is received
+	Report         func(Progress)
+	ReportInterval time.Duration
+}
+
+// Download writes the image at opts.URL to opts.Dir, unless a verified
+// copy is already there, and returns its path. A download that is cut
+// short or does not match opts.SHA256 is thrown away and retried with
+// opts.Backoff, and so is one where the server stops sending data, so
+// the image only appears in opts.Dir once verified.
+func Download(ctx context.Context, opts Options) (string, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse image URL %q: %v", opts.URL, err)
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", fmt.Errorf("image URL %q does not name a file", opts.URL)
+	}
+	expected := strings.ToLower(opts.SHA256)
+	if expected == "" {
+		return "", fmt.Errorf("no sha256 to verify %s against", name)
+	}
+	if opts.Client == nil {
+		opts.Client = defaultClient
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.Report == nil {
+		opts.Report = func(Progress) {}
+	}
+	file := filepath.Join(opts.Dir, name)
+
+	// The image survives restarts of the pod in the cache of the node
+	if size, checksum, err := fileChecksum(file); err == nil && checksum == expected {
+		opts.Report(Progress{Phase: metal3iov1alpha1.ImageCacheDownloaded, DownloadedBytes: size, TotalBytes: size, SHA256: checksum})
+		return file, nil
+	}
+
+	backoff := opts.Backoff
+	for attempt := 1; ; attempt++ {
+		progress := Progress{Phase: metal3iov1alpha1.ImageCacheDownloading, Attempt: attempt}
+		err := download(ctx, opts, file, &progress)
+		if err == nil {
+			progress.Phase = metal3iov1alpha1.ImageCacheDownloaded
+			progress.SHA256 = expected
+			opts.Report(progress)
+			return file, nil
+		}
+
+		progress.Message = err.Error()
+		if backoff.Steps <= 1 {
+			progress.Phase = metal3iov1alpha1.ImageCacheFailed
+			opts.Report(progress)
+			return "", fmt.Errorf("unable to download %s after %d attempts: %v", name, attempt, err)
+		}
+		opts.Report(progress)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// download makes one attempt at writing the image to file, through a
+// temporary file which is only renamed once verified. The attempt is
+// cancelled once the server sent nothing for opts.IdleTimeout.
+func download(ctx context.Context, opts Options, file string, progress *Progress) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled int32
+	idle := time.AfterFunc(opts.IdleTimeout, func() {
+		atomic.StoreInt32(&stalled, 1)
+		cancel()
+	})
+	defer idle.Stop()
+	stalledErr := func(err error) error {
+		if atomic.LoadInt32(&stalled) == 1 {
+			return fmt.Errorf("no data received for %s", opts.IdleTimeout)
+		}
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return err
+	}
+	// Keep compressed images as they are, the checksum is theirs
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := opts.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return stalledErr(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	if resp.ContentLength > 0 {
+		progress.TotalBytes = resp.ContentLength
+	}
+	opts.Report(*progress)
+
+	tmp, err := ioutil.TempFile(opts.Dir, "."+filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &progressWriter{progress: progress, report: opts.Report, interval: opts.ReportInterval, last: time.Now(),
+		idle: idle, idleTimeout: opts.IdleTimeout}
+	_, err = io.Copy(io.MultiWriter(tmp, hash, counter), resp.Body)
+	switch {
+	case err != nil:
+		return fmt.Errorf("download interrupted after %d bytes: %v", progress.DownloadedBytes, stalledErr(err))
+	case resp.ContentLength >= 0 && progress.DownloadedBytes != resp.ContentLength:
+		return fmt.Errorf("download truncated to %d of %d bytes", progress.DownloadedBytes, resp.ContentLength)
+	}
+
+	progress.Phase = metal3iov1alpha1.ImageCacheVerifying
+	opts.Report(*progress)
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != strings.ToLower(opts.SHA256) {
+		return fmt.Errorf("sha256 mismatch: downloaded %s, expected %s", checksum, strings.ToLower(opts.SHA256))
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// fileChecksum returns the size and the hex encoded sha256 of file.
+func fileChecksum(file string) (int64, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// progressWriter counts the bytes written to it into progress, and
+// reports it at most once per interval. Every write pushes the idle
+// timeout of the attempt back.
+type progressWriter struct {
+	progress    *Progress
+	report      func(Progress)
+	interval    time.Duration
+	last        time.Time
+	idle        *time.Timer
+	idleTimeout time.Duration
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.idle.Reset(w.idleTimeout)
+	w.progress.DownloadedBytes += int64(len(p))
+	if now := time.Now(); now.Sub(w.last) >= w.interval {
+		w.last = now
+		w.report(*w.progress)
+	}
+	return len(p), nil
+}
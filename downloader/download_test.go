@@ -0,0 +1,274 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+var testImage = []byte("QFI\xfb" + string(make([]byte, 64*1024)) + "rhcos")
+
+func testImageSHA256() string {
+	sum := sha256.Sum256(testImage)
+	return hex.EncodeToString(sum[:])
+}
+
+// Each handler serves the image differently
+var (
+	serveGood = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testImage)))
+		w.Write(testImage)
+	}
+	serveTruncated = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testImage)))
+		w.Write(testImage[:len(testImage)/2])
+	}
+	serveCorrupt = func(w http.ResponseWriter, r *http.Request) {
+		corrupt := append([]byte{}, testImage...)
+		corrupt[len(corrupt)/2] ^= 0xff
+		w.Write(corrupt)
+	}
+	serveMissing = func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}
+	serveStalled = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testImage)))
+		w.Write(testImage[:len(testImage)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+)
+
+func TestDownload(t *testing.T) {
+	testCases := []struct {
+		name             string
+		handlers         []http.HandlerFunc
+		expectedRequests int32
+		expectedError    string
+		expectedMessages []string
+	}{
+		{
+			name:             "Good",
+			handlers:         []http.HandlerFunc{serveGood},
+			expectedRequests: 1,
+		},
+		{
+			name:             "Truncated",
+			handlers:         []http.HandlerFunc{serveTruncated},
+			expectedRequests: 3,
+			expectedError:    "unable to download rhcos.qcow2.gz after 3 attempts: download interrupted",
+		},
+		{
+			name:             "Corrupt",
+			handlers:         []http.HandlerFunc{serveCorrupt},
+			expectedRequests: 3,
+			expectedError:    "unable to download rhcos.qcow2.gz after 3 attempts: sha256 mismatch",
+		},
+		{
+			name:             "Missing",
+			handlers:         []http.HandlerFunc{serveMissing},
+			expectedRequests: 3,
+			expectedError:    "server answered 404 Not Found",
+		},
+		{
+			name:             "Stalled",
+			handlers:         []http.HandlerFunc{serveStalled},
+			expectedRequests: 3,
+			expectedError:    "unable to download rhcos.qcow2.gz after 3 attempts: download interrupted after 32772 bytes: no data received for 100ms",
+		},
+		{
+			name:             "RecoversFromStalled",
+			handlers:         []http.HandlerFunc{serveStalled, serveGood},
+			expectedRequests: 2,
+			expectedMessages: []string{"download interrupted after 32772 bytes: no data received for 100ms"},
+		},
+		{
+			name:             "RecoversFromCorruptAndTruncated",
+			handlers:         []http.HandlerFunc{serveCorrupt, serveTruncated, serveGood},
+			expectedRequests: 3,
+			expectedMessages: []string{"sha256 mismatch", "download interrupted"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n > len(tc.handlers) {
+					n = len(tc.handlers)
+				}
+				tc.handlers[n-1](w, r)
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "image-cache")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+
+			reports := []Progress{}
+			file, err := Download(context.Background(), Options{
+				URL:         server.URL + "/images/rhcos.qcow2.gz?sha256=" + testImageSHA256(),
+				SHA256:      testImageSHA256(),
+				Dir:         dir,
+				Backoff:     wait.Backoff{Duration: time.Millisecond, Steps: 3},
+				IdleTimeout: 100 * time.Millisecond,
+				Report:      func(p Progress) { reports = append(reports, p) },
+			})
+			assert.Equal(t, tc.expectedRequests, atomic.LoadInt32(&requests))
+			last := reports[len(reports)-1]
+			messages := []string{}
+			for _, p := range reports {
+				if p.Message != "" && (len(messages) == 0 || messages[len(messages)-1] != p.Message) {
+					messages = append(messages, p.Message)
+				}
+			}
+
+			if tc.expectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				assert.Equal(t, metal3iov1alpha1.ImageCacheFailed, last.Phase)
+				assert.Equal(t, 3, last.Attempt)
+				assert.Empty(t, last.SHA256)
+				// Nothing unverified is left in the cache
+				entries, _ := ioutil.ReadDir(dir)
+				assert.Empty(t, entries)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, filepath.Join(dir, "rhcos.qcow2.gz"), file)
+			data, err := ioutil.ReadFile(file)
+			assert.NoError(t, err)
+			assert.Equal(t, testImage, data)
+			entries, _ := ioutil.ReadDir(dir)
+			assert.Len(t, entries, 1)
+
+			assert.Equal(t, Progress{
+				Phase:           metal3iov1alpha1.ImageCacheDownloaded,
+				Attempt:         int(tc.expectedRequests),
+				DownloadedBytes: int64(len(testImage)),
+				TotalBytes:      int64(len(testImage)),
+				SHA256:          testImageSHA256(),
+			}, last)
+			for i, msg := range tc.expectedMessages {
+				if assert.True(t, len(messages) > i) {
+					assert.Contains(t, messages[i], msg)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadCached(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		serveGood(w, r)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "image-cache")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	opts := Options{
+		URL:    server.URL + "/rhcos.qcow2.gz",
+		SHA256: testImageSHA256(),
+		Dir:    dir,
+	}
+
+	// A corrupt copy is replaced
+	file := filepath.Join(dir, "rhcos.qcow2.gz")
+	assert.NoError(t, ioutil.WriteFile(file, testImage[1:], 0644))
+	_, err = Download(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// A verified copy is kept
+	var last Progress
+	opts.Report = func(p Progress) { last = p }
+	_, err = Download(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, metal3iov1alpha1.ImageCacheDownloaded, last.Phase)
+	assert.Equal(t, testImageSHA256(), last.SHA256)
+}
+
+func TestDownloadReportsProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testImage)))
+		for i := 0; i < len(testImage); i += 8 * 1024 {
+			end := i + 8*1024
+			if end > len(testImage) {
+				end = len(testImage)
+			}
+			w.Write(testImage[i:end])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "image-cache")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	reports := []Progress{}
+	_, err = Download(context.Background(), Options{
+		URL:    server.URL + "/rhcos.qcow2.gz",
+		SHA256: testImageSHA256(),
+		Dir:    dir,
+		Report: func(p Progress) { reports = append(reports, p) },
+	})
+	assert.NoError(t, err)
+
+	phases := []metal3iov1alpha1.ImageCachePhase{}
+	var downloaded int64
+	for _, p := range reports {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+		assert.True(t, p.DownloadedBytes >= downloaded, "progress went backwards")
+		downloaded = p.DownloadedBytes
+		assert.Equal(t, int64(len(testImage)), p.TotalBytes)
+	}
+	assert.Equal(t, []metal3iov1alpha1.ImageCachePhase{
+		metal3iov1alpha1.ImageCacheDownloading,
+		metal3iov1alpha1.ImageCacheVerifying,
+		metal3iov1alpha1.ImageCacheDownloaded,
+	}, phases)
+	// Reported along the way, not only once done
+	assert.True(t, len(reports) > 4)
+}
+
+func TestDownloadInvalidOptions(t *testing.T) {
+	_, err := Download(context.Background(), Options{URL: "http://172.22.0.1/", SHA256: testImageSHA256()})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not name a file")
+	}
+	_, err = Download(context.Background(), Options{URL: "http://172.22.0.1/rhcos.qcow2.gz"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no sha256 to verify rhcos.qcow2.gz against")
+	}
+}
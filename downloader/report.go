@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProgressAnnotation is set on the image cache pods to the JSON encoded
+// Progress of their download.
+const ProgressAnnotation = "baremetal.openshift.io/image-download"
+
+// PodReporter returns a Report function publishing the progress in
+// ProgressAnnotation of the pod namespace/name, the one the download
+// runs in. Failures to publish are logged, as they do not affect the
+// download.
+func PodReporter(c client.Client, namespace, name string, log logr.Logger) func(Progress) {
+	return func(progress Progress) {
+		log.Info("download", "phase", progress.Phase, "attempt", progress.Attempt,
+			"bytes", progress.DownloadedBytes, "total", progress.TotalBytes, "message", progress.Message)
+
+		value, err := json.Marshal(progress)
+		if err != nil {
+			log.Error(err, "unable to encode progress")
+			return
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{ProgressAnnotation: string(value)},
+			},
+		})
+		if err != nil {
+			log.Error(err, "unable to encode progress")
+			return
+		}
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		if err := c.Patch(context.Background(), pod, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			log.Error(err, "unable to publish progress", "pod", name)
+		}
+	}
+}
+
+// PodProgress returns the progress published on pod, or nil when none
+// was.
+func PodProgress(pod *corev1.Pod) (*Progress, error) {
+	value, found := pod.Annotations[ProgressAnnotation]
+	if !found {
+		return nil, nil
+	}
+	progress := &Progress{}
+	if err := json.Unmarshal([]byte(value), progress); err != nil {
+		return nil, fmt.Errorf("could not parse annotation %s of pod %s: %v", ProgressAnnotation, pod.Name, err)
+	}
+	return progress, nil
+}
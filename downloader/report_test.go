@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestPodReporter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-machine-api", Name: "metal3-image-cache-x7k2p"}}
+	c := fake.NewFakeClientWithScheme(scheme, pod)
+
+	progress, err := PodProgress(pod)
+	assert.NoError(t, err)
+	assert.Nil(t, progress)
+
+	report := PodReporter(c, pod.Namespace, pod.Name, log.NullLogger{})
+	report(Progress{Phase: metal3iov1alpha1.ImageCacheDownloading, Attempt: 1, DownloadedBytes: 1024, TotalBytes: 4096})
+	report(Progress{Phase: metal3iov1alpha1.ImageCacheDownloading, Attempt: 1, DownloadedBytes: 2048, TotalBytes: 4096})
+
+	live := &corev1.Pod{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, live))
+	progress, err = PodProgress(live)
+	assert.NoError(t, err)
+	assert.Equal(t, &Progress{Phase: metal3iov1alpha1.ImageCacheDownloading, Attempt: 1, DownloadedBytes: 2048, TotalBytes: 4096}, progress)
+
+	live.Annotations[ProgressAnnotation] = "{"
+	_, err = PodProgress(live)
+	assert.Error(t, err)
+}
@@ -16,16 +16,18 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
-	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	// +kubebuilder:scaffold:imports
@@ -33,7 +35,9 @@ import (
 	osconfigv1 "github.com/openshift/api/config/v1"
 	osclientset "github.com/openshift/client-go/config/clientset/versioned"
 	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+	metal3iov1beta1 "github.com/openshift/cluster-baremetal-operator/api/v1beta1"
 	"github.com/openshift/cluster-baremetal-operator/controllers"
+	"github.com/openshift/cluster-baremetal-operator/downloader"
 )
 
 var (
@@ -51,6 +55,11 @@ func init() {
 		setupLog.Error(err, "Error adding k8s client to scheme.")
 		os.Exit(1)
 	}
+
+	if err := metal3iov1beta1.AddToScheme(scheme); err != nil {
+		setupLog.Error(err, "Error adding k8s client to scheme.")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:scheme
 	// The following is needed to read the Infrastructure CR
 	if err := osconfigv1.Install(scheme); err != nil {
@@ -59,12 +68,101 @@ func init() {
 	}
 }
 
+// render implements the render subcommand, which writes the manifests
+// the operator would apply without running it.
+func render(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	provisioningFile := flags.String("provisioning", "", "The Provisioning CR to render the manifests of.")
+	imagesFile := flags.String("images", "", "The images JSON listing the container images of the metal3 pod.")
+	infrastructureFile := flags.String("infrastructure", "", "The Infrastructure config of the cluster.")
+	proxyFile := flags.String("proxy", "", "The Proxy config of the cluster, whose status the metal3 containers use. Optional.")
+	outDir := flags.String("out", "", "The directory the manifests are written to.")
+	flags.Parse(args)
+
+	if *provisioningFile == "" || *imagesFile == "" || *infrastructureFile == "" || *outDir == "" {
+		fmt.Fprintln(os.Stderr, "--provisioning, --images, --infrastructure and --out are required")
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err := controllers.Render(*provisioningFile, *imagesFile, *infrastructureFile, *proxyFile, *outDir); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to render: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// download implements the download subcommand, which the image cache
+// pods run to fetch and verify the OS image. The progress is published
+// on the pod named by the POD_NAMESPACE and POD_NAME environment
+// variables when they are set.
+func download(args []string) {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	imageURL := flags.String("url", "", "The URL of the OS image.")
+	checksum := flags.String("sha256", "", "The sha256 the OS image must match.")
+	dir := flags.String("dir", "", "The directory the OS image is written to.")
+	flags.Parse(args)
+
+	if *imageURL == "" || *checksum == "" || *dir == "" {
+		fmt.Fprintln(os.Stderr, "--url, --sha256 and --dir are required")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctrl.SetLogger(zap.New(func(o *zap.Options) {
+		o.Development = true
+	}))
+	log := ctrl.Log.WithName("download")
+	opts := downloader.Options{
+		URL:            *imageURL,
+		SHA256:         *checksum,
+		Dir:            *dir,
+		Backoff:        downloader.DefaultBackoff,
+		ReportInterval: 10 * time.Second,
+	}
+	if namespace, name := os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"); namespace != "" && name != "" {
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			log.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		opts.Report = downloader.PodReporter(c, namespace, name, log)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := ctrl.SetupSignalHandler()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	file, err := downloader.Download(ctx, opts)
+	if err != nil {
+		log.Error(err, "unable to download the OS image")
+		os.Exit(1)
+	}
+	log.Info("downloaded the OS image", "file", file, "sha256", *checksum)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "render":
+			render(os.Args[2:])
+			return
+		case "download":
+			download(os.Args[2:])
+			return
+		}
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
+	var imagesFile string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&metal3iov1alpha1.RejectNonSingleton, "reject-non-singleton-provisioning", true,
+		fmt.Sprintf("Reject Provisioning objects not named %s in the validating webhook. When false they are admitted and marked as ignored by the operator.", metal3iov1alpha1.ProvisioningSingletonName))
+	flag.StringVar(&imagesFile, "images-json", "/etc/cluster-baremetal-operator/images/images.json",
+		"The location of the file containing the images to use for the metal3 pod.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
@@ -84,18 +182,28 @@ func main() {
 	}
 
 	osClient := osclientset.NewForConfigOrDie(rest.AddUserAgent(config, controllers.ComponentName))
-	recorder := record.NewBroadcaster().NewRecorder(clientgoscheme.Scheme, v1.EventSource{Component: controllers.ComponentName})
-
 	if err = (&controllers.ProvisioningReconciler{
 		Client:        mgr.GetClient(),
 		Log:           ctrl.Log.WithName("controllers").WithName("Provisioning"),
 		Scheme:        mgr.GetScheme(),
 		OSClient:      osClient,
-		EventRecorder: recorder,
+		EventRecorder: mgr.GetEventRecorderFor(controllers.ComponentName),
+		ImagesFile:    imagesFile,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Provisioning")
 		os.Exit(1)
 	}
+	// Webhooks need serving certificates, allow running locally without them.
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&metal3iov1alpha1.Provisioning{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Provisioning")
+			os.Exit(1)
+		}
+		if err = (&metal3iov1beta1.Provisioning{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Provisioning")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,107 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// IronicCredentialsSecretName is the name of the Secret holding the
+	// basic auth credentials of the ironic API
+	IronicCredentialsSecretName = "metal3-ironic-password"
+	// InspectorCredentialsSecretName is the name of the Secret holding
+	// the basic auth credentials of the ironic-inspector API
+	InspectorCredentialsSecretName = "metal3-ironic-inspector-password"
+
+	// UsernameKey and PasswordKey are the keys of the client credentials
+	// in the credentials Secrets, HtpasswdKey the key of the htpasswd
+	// file the API checks them against.
+	UsernameKey = "username"
+	PasswordKey = "password"
+	HtpasswdKey = "htpasswd"
+
+	// IronicUsername and InspectorUsername are the users of the APIs
+	IronicUsername    = "ironic-user"
+	InspectorUsername = "inspector-user"
+
+	ironicCredentialsVolume    = "metal3-ironic-credentials"
+	inspectorCredentialsVolume = "metal3-ironic-inspector-credentials"
+	ironicCredentialsPath      = "/auth/ironic"
+	inspectorCredentialsPath   = "/auth/ironic-inspector"
+)
+
+// Htpasswd returns the htpasswd line of username, with the password
+// hashed with bcrypt.
+func Htpasswd(username, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash the password of %s: %v", username, err)
+	}
+	return fmt.Sprintf("%s:%s", username, hash), nil
+}
+
+// credentialsVolumes are the client credentials of the ironic and
+// ironic-inspector APIs, without the htpasswd files.
+var credentialsVolumes = []corev1.Volume{
+	credentialsVolume(ironicCredentialsVolume, IronicCredentialsSecretName),
+	credentialsVolume(inspectorCredentialsVolume, InspectorCredentialsSecretName),
+}
+
+func credentialsVolume(name, secretName string) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+				Items: []corev1.KeyToPath{
+					{Key: UsernameKey, Path: UsernameKey},
+					{Key: PasswordKey, Path: PasswordKey},
+				},
+			},
+		},
+	}
+}
+
+var (
+	ironicCredentialsMount = corev1.VolumeMount{
+		Name:      ironicCredentialsVolume,
+		MountPath: ironicCredentialsPath,
+		ReadOnly:  true,
+	}
+	inspectorCredentialsMount = corev1.VolumeMount{
+		Name:      inspectorCredentialsVolume,
+		MountPath: inspectorCredentialsPath,
+		ReadOnly:  true,
+	}
+)
+
+// htpasswdEnv passes the htpasswd file of the API whose credentials are
+// in secretName.
+func htpasswdEnv(name, secretName string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  HtpasswdKey,
+			},
+		},
+	}
+}
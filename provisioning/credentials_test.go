@@ -0,0 +1,22 @@
+package provisioning
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHtpasswd(t *testing.T) {
+	line, err := Htpasswd(IronicUsername, "s3cret")
+	if !assert.NoError(t, err) {
+		return
+	}
+	fields := strings.SplitN(line, ":", 2)
+	if assert.Len(t, fields, 2) {
+		assert.Equal(t, IronicUsername, fields[0])
+		assert.NotContains(t, fields[1], "s3cret")
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(fields[1]), []byte("s3cret")))
+	}
+}
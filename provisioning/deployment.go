@@ -0,0 +1,371 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// Metal3DeploymentName is the name of the Deployment running the
+	// metal3 services
+	Metal3DeploymentName = "metal3"
+	// DnsmasqConfigMapName is the name of the ConfigMap holding the
+	// configuration rendered by DnsmasqConfig
+	DnsmasqConfigMapName = "metal3-dnsmasq-config"
+	// MariadbPasswordSecretName is the name of the Secret holding the
+	// password of the ironic database
+	MariadbPasswordSecretName = "metal3-mariadb-password"
+	// MariadbPasswordSecretKey is the key of the password in that Secret
+	MariadbPasswordSecretKey = "password"
+
+	dnsmasqConfigKey = "dnsmasq.conf"
+
+	sharedVolume        = "metal3-shared"
+	sharedMountPath     = "/shared"
+	dnsmasqConfigVolume = "metal3-dnsmasq-config"
+	dnsmasqConfigPath   = "/etc/metal3-dnsmasq"
+)
+
+var metal3Labels = map[string]string{
+	"k8s-app": Metal3DeploymentName,
+}
+
+// Manifests returns the objects running the metal3 services for a
+// defaulted and valid Provisioning spec: the ConfigMaps and the image
+// cache the Topology needs, the Services of the APIs and the ironic
+// proxy, then the Deployment. Their containers go through proxy, which
+// is nil when the cluster has none. The Secrets and the CA bundle the
+// Deployment refers to are generated in the cluster, and are not part
+// of them.
+func Manifests(prov *metal3iov1alpha1.Provisioning, images *Images, proxy *Proxy, namespace string) ([]runtime.Object, error) {
+	objects := []runtime.Object{NewTrustedCABundleConfigMap(namespace)}
+	if NewTopology(prov).Dnsmasq {
+		cm, err := NewDnsmasqConfigMap(prov, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render dnsmasq configuration: %v", err)
+		}
+		objects = append(objects, cm)
+	}
+	if NewTopology(prov).ImageCache {
+		imageCache := NewImageCacheManifests(prov, images, namespace)
+		for _, obj := range imageCache {
+			if ds, ok := obj.(*appsv1.DaemonSet); ok {
+				setProxy(&ds.Spec.Template.Spec, prov, proxy, namespace)
+			}
+		}
+		objects = append(objects, imageCache...)
+	}
+	objects = append(objects, NewServiceManifests(prov, namespace)...)
+	if NewTopology(prov).IronicProxy {
+		objects = append(objects, NewIronicProxyDaemonSet(prov, images, namespace))
+	}
+	deployment := NewMetal3Deployment(prov, images, namespace)
+	setProxy(&deployment.Spec.Template.Spec, prov, proxy, namespace)
+	return append(objects, deployment), nil
+}
+
+// NewMetal3Deployment returns the Deployment running the metal3 services
+// configured from a defaulted and valid Provisioning spec. Its pod uses
+// the host network, so that ironic can reach the provisioning network.
+func NewMetal3Deployment(prov *metal3iov1alpha1.Provisioning, images *Images, namespace string) *appsv1.Deployment {
+	replicas := int32(1)
+
+	containers := []corev1.Container{
+		newBaremetalOperatorContainer(prov, images),
+		newIronicContainer(prov, images),
+		newInspectorContainer(prov, images),
+		newHTTPDContainer(prov, images),
+		newMariadbContainer(images),
+	}
+	volumes := append([]corev1.Volume{
+		{
+			Name:         sharedVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+	}, credentialsVolumes...)
+	volumes = append(volumes, tlsVolumes...)
+	topology := NewTopology(prov)
+	if topology.Dnsmasq {
+		containers = append(containers, newDnsmasqContainer(prov, images))
+		volumes = append(volumes, corev1.Volume{
+			Name: dnsmasqConfigVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: DnsmasqConfigMapName},
+				},
+			},
+		})
+	}
+	if topology.StaticIPManager {
+		containers = append(containers, newStaticIPManagerContainer(prov, images))
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Metal3DeploymentName,
+			Namespace: namespace,
+			Labels:    metal3Labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: metal3Labels,
+			},
+			// The pod listens on host ports, a new one can't start
+			// on the same host before the old one is gone.
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: metal3Labels,
+				},
+				Spec: corev1.PodSpec{
+					HostNetwork: true,
+					DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+					// Next to the image cache
+					NodeSelector:      nodeSelector(prov),
+					Tolerations:       tolerations(prov),
+					PriorityClassName: metal3PriorityClassName,
+					InitContainers: []corev1.Container{
+						newIpaDownloaderContainer(images),
+						newMachineOsDownloaderContainer(prov, images),
+					},
+					Containers: containers,
+					Volumes:    volumes,
+				},
+			},
+		},
+	}
+}
+
+// NewDnsmasqConfigMap returns the ConfigMap holding the dnsmasq
+// configuration, which the metal3 pod only mounts when its Topology
+// includes dnsmasq.
+func NewDnsmasqConfigMap(prov *metal3iov1alpha1.Provisioning, namespace string) (*corev1.ConfigMap, error) {
+	config, err := DnsmasqConfig(prov)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DnsmasqConfigMapName,
+			Namespace: namespace,
+			Labels:    metal3Labels,
+		},
+		Data: map[string]string{
+			dnsmasqConfigKey: config,
+		},
+	}, nil
+}
+
+var sharedVolumeMount = corev1.VolumeMount{
+	Name:      sharedVolume,
+	MountPath: sharedMountPath,
+}
+
+// The downloaders fetch the images served by httpd before the other
+// containers start.
+func newIpaDownloaderContainer(images *Images) corev1.Container {
+	return corev1.Container{
+		Name:         "metal3-ipa-downloader",
+		Image:        images.IpaDownloader,
+		Command:      []string{"/usr/local/bin/get-resource.sh"},
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount},
+	}
+}
+
+// The OS image is fetched from the image cache of the node, rather than
+// from ProvisioningOSDownloadURL every time the pod starts.
+func newMachineOsDownloaderContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	imageURL := prov.Spec.ProvisioningOSDownloadURL
+	if NewTopology(prov).ImageCache {
+		imageURL = ImageCacheURL(prov, "localhost")
+	}
+	return corev1.Container{
+		Name:         "metal3-machine-os-downloader",
+		Image:        images.MachineOsDownloader,
+		Command:      []string{"/usr/local/bin/get-resource.sh"},
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount},
+		Env: []corev1.EnvVar{
+			{Name: "RHCOS_IMAGE_URL", Value: imageURL},
+		},
+	}
+}
+
+func newBaremetalOperatorContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	return corev1.Container{
+		Name:    "metal3-baremetal-operator",
+		Image:   images.BaremetalOperator,
+		Command: []string{"/baremetal-operator"},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: ironicCredentialsVolume, MountPath: "/opt/metal3/auth/ironic", ReadOnly: true},
+			{Name: inspectorCredentialsVolume, MountPath: "/opt/metal3/auth/ironic-inspector", ReadOnly: true},
+			{Name: caBundleVolume, MountPath: "/opt/metal3/certs/ca", ReadOnly: true},
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name: "WATCH_NAMESPACE",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+				},
+			},
+			{Name: "OPERATOR_NAME", Value: "baremetal-operator"},
+			{Name: "IRONIC_ENDPOINT", Value: IronicEndpoint(IronicIP(prov))},
+			{Name: "IRONIC_INSPECTOR_ENDPOINT", Value: InspectorEndpoint(IronicIP(prov))},
+			{Name: "DEPLOY_KERNEL_URL", Value: HTTPURL(HTTPIP(prov), "/images/ironic-python-agent.kernel")},
+			{Name: "DEPLOY_RAMDISK_URL", Value: HTTPURL(HTTPIP(prov), "/images/ironic-python-agent.initramfs")},
+			{Name: "IRONIC_CACERT_FILE", Value: "/opt/metal3/certs/ca/" + corev1.TLSCertKey},
+		},
+	}
+}
+
+func newIronicContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	return corev1.Container{
+		Name:         "metal3-ironic",
+		Image:        images.Ironic,
+		Command:      []string{"/bin/runironic"},
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount, inspectorCredentialsMount, tlsMount("ironic"), caBundleMount},
+		Env: append([]corev1.EnvVar{
+			{Name: "PROVISIONING_IP", Value: IronicIP(prov)},
+			{Name: "IRONIC_ENDPOINT", Value: IronicEndpoint(IronicIP(prov))},
+			{Name: "HTTP_URL", Value: HTTPURL(HTTPIP(prov), "/")},
+			{Name: "HTTP_PORT", Value: strconv.Itoa(HTTPPort)},
+			{Name: "PXE_ENABLED", Value: strconv.FormatBool(prov.PXEAllowed())},
+			// ironic hands the CA to the ramdisks it boots, for the
+			// lookup, heartbeats and inspection data of the agent
+			{Name: "OS_AGENT__API_CA_FILE", Value: caBundleFile},
+			mariadbPasswordEnv,
+			htpasswdEnv("IRONIC_HTPASSWD", IronicCredentialsSecretName),
+		}, ProvisioningInterfaceEnv(prov)...),
+		Ports: []corev1.ContainerPort{
+			{Name: "ironic", ContainerPort: IronicPort},
+		},
+	}
+}
+
+func newInspectorContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	return corev1.Container{
+		Name:         "metal3-ironic-inspector",
+		Image:        images.IronicInspector,
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount, ironicCredentialsMount, tlsMount("ironic-inspector"), caBundleMount},
+		Env: append([]corev1.EnvVar{
+			{Name: "PROVISIONING_IP", Value: IronicIP(prov)},
+			{Name: "IRONIC_ENDPOINT", Value: IronicEndpoint(IronicIP(prov))},
+			{Name: "INSPECTOR_ENDPOINT", Value: InspectorEndpoint(IronicIP(prov))},
+			htpasswdEnv("INSPECTOR_HTPASSWD", InspectorCredentialsSecretName),
+		}, ProvisioningInterfaceEnv(prov)...),
+		Ports: []corev1.ContainerPort{
+			{Name: "inspector", ContainerPort: InspectorPort},
+		},
+	}
+}
+
+func newHTTPDContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	return corev1.Container{
+		Name:         "metal3-httpd",
+		Image:        images.Ironic,
+		Command:      []string{"/bin/runhttpd"},
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount},
+		Env: []corev1.EnvVar{
+			{Name: "PROVISIONING_IP", Value: HTTPIP(prov)},
+			{Name: "HTTP_PORT", Value: strconv.Itoa(HTTPPort)},
+		},
+		Ports: []corev1.ContainerPort{
+			{Name: "http", ContainerPort: HTTPPort},
+		},
+	}
+}
+
+var mariadbPasswordEnv = corev1.EnvVar{
+	Name: "MARIADB_PASSWORD",
+	ValueFrom: &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: MariadbPasswordSecretName},
+			Key:                  MariadbPasswordSecretKey,
+		},
+	},
+}
+
+func newMariadbContainer(images *Images) corev1.Container {
+	return corev1.Container{
+		Name:         "metal3-mariadb",
+		Image:        images.Ironic,
+		Command:      []string{"/bin/runmariadb"},
+		VolumeMounts: []corev1.VolumeMount{sharedVolumeMount},
+		Env:          []corev1.EnvVar{mariadbPasswordEnv},
+	}
+}
+
+func newDnsmasqContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	privileged := true
+	dhcpPort := corev1.ContainerPort{Name: "dhcp", ContainerPort: 67, Protocol: corev1.ProtocolUDP}
+	if ip := net.ParseIP(prov.Spec.ProvisioningIP); ip != nil && ip.To4() == nil {
+		dhcpPort = corev1.ContainerPort{Name: "dhcpv6", ContainerPort: 547, Protocol: corev1.ProtocolUDP}
+	}
+	return corev1.Container{
+		Name:    "metal3-dnsmasq",
+		Image:   images.Ironic,
+		Command: DnsmasqCommand(dnsmasqConfigPath + "/" + dnsmasqConfigKey),
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			sharedVolumeMount,
+			{Name: dnsmasqConfigVolume, MountPath: dnsmasqConfigPath, ReadOnly: true},
+		},
+		Ports: []corev1.ContainerPort{
+			dhcpPort,
+			{Name: "tftp", ContainerPort: 69, Protocol: corev1.ProtocolUDP},
+		},
+	}
+}
+
+func newStaticIPManagerContainer(prov *metal3iov1alpha1.Provisioning, images *Images) corev1.Container {
+	privileged := true
+	return corev1.Container{
+		Name:    "metal3-static-ip-manager",
+		Image:   images.StaticIPManager,
+		Command: WithProvisioningInterface("exec /refresh-static-ip"),
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+		Env: append([]corev1.EnvVar{
+			{Name: "PROVISIONING_IP", Value: staticIP(prov)},
+		}, ProvisioningInterfaceEnv(prov)...),
+	}
+}
+
+// staticIP returns the ProvisioningIP with the prefix length of the
+// provisioning network, as the static-ip-manager assigns it.
+func staticIP(prov *metal3iov1alpha1.Provisioning) string {
+	_, cidr, err := net.ParseCIDR(prov.Spec.ProvisioningNetworkCIDR)
+	if err != nil {
+		return prov.Spec.ProvisioningIP
+	}
+	prefixLength, _ := cidr.Mask.Size()
+	return fmt.Sprintf("%s/%d", prov.Spec.ProvisioningIP, prefixLength)
+}
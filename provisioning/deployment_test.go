@@ -0,0 +1,197 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+var testImages = &Images{
+	ClusterBaremetalOperator: "quay.io/openshift/origin-cluster-baremetal-operator:latest",
+	BaremetalOperator:        "quay.io/openshift/origin-baremetal-operator:latest",
+	Ironic:                   "quay.io/openshift/origin-ironic:latest",
+	IronicInspector:          "quay.io/openshift/origin-ironic-inspector:latest",
+	IpaDownloader:            "quay.io/openshift/origin-ironic-ipa-downloader:latest",
+	MachineOsDownloader:      "quay.io/openshift/origin-ironic-machine-os-downloader:latest",
+	StaticIPManager:          "quay.io/openshift/origin-ironic-static-ip-manager:latest",
+}
+
+func containerNames(containers []corev1.Container) []string {
+	names := []string{}
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func findContainer(containers []corev1.Container, name string) corev1.Container {
+	for _, c := range containers {
+		if c.Name == name {
+			return c
+		}
+	}
+	return corev1.Container{}
+}
+
+func envValue(container corev1.Container, name string) string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+func TestNewMetal3Deployment(t *testing.T) {
+	testCases := []struct {
+		name               string
+		spec               metal3iov1alpha1.ProvisioningSpec
+		expectedContainers []string
+		expectedIronicIP   string
+		expectedHTTPURL    string
+	}{
+		{
+			name: "Managed",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.10,172.30.20.100",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedContainers: []string{"metal3-baremetal-operator", "metal3-ironic", "metal3-ironic-inspector", "metal3-httpd", "metal3-mariadb", "metal3-dnsmasq", "metal3-static-ip-manager"},
+			expectedIronicIP:   "172.30.20.3",
+			expectedHTTPURL:    "http://172.30.20.3:6180/",
+		},
+		{
+			name: "UnmanagedIPv6",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningMacAddresses: []string{"52:54:00:aa:bb:01"},
+				ProvisioningIP:           "fd00:1101::3",
+				ProvisioningNetworkCIDR:  "fd00:1101::/64",
+				ProvisioningNetwork:      metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+			},
+			expectedContainers: []string{"metal3-baremetal-operator", "metal3-ironic", "metal3-ironic-inspector", "metal3-httpd", "metal3-mariadb", "metal3-static-ip-manager"},
+			expectedIronicIP:   "fd00:1101::3",
+			expectedHTTPURL:    "http://[fd00:1101::3]:6180/",
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+				ExternalHTTPIP:      "192.168.111.11",
+			},
+			expectedContainers: []string{"metal3-baremetal-operator", "metal3-ironic", "metal3-ironic-inspector", "metal3-httpd", "metal3-mariadb"},
+			expectedIronicIP:   "192.168.111.10",
+			expectedHTTPURL:    "http://192.168.111.11:6180/",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+
+			deployment := NewMetal3Deployment(prov, testImages, "openshift-machine-api")
+			assert.Equal(t, "openshift-machine-api", deployment.Namespace)
+			assert.True(t, deployment.Spec.Template.Spec.HostNetwork)
+
+			containers := deployment.Spec.Template.Spec.Containers
+			assert.Equal(t, tc.expectedContainers, containerNames(containers))
+			ironic := findContainer(containers, "metal3-ironic")
+			assert.Equal(t, tc.expectedIronicIP, envValue(ironic, "PROVISIONING_IP"))
+			assert.Equal(t, tc.expectedHTTPURL, envValue(ironic, "HTTP_URL"))
+
+			initContainers := deployment.Spec.Template.Spec.InitContainers
+			assert.Equal(t, []string{"metal3-ipa-downloader", "metal3-machine-os-downloader"}, containerNames(initContainers))
+			for _, c := range append(initContainers, containers...) {
+				assert.NotEmpty(t, c.Image, c.Name)
+			}
+
+			volumes := []string{}
+			for _, v := range deployment.Spec.Template.Spec.Volumes {
+				volumes = append(volumes, v.Name)
+			}
+			dnsmasq := findContainer(containers, "metal3-dnsmasq")
+			assert.Subset(t, volumes, []string{sharedVolume, ironicCredentialsVolume, inspectorCredentialsVolume})
+			assert.Equal(t, dnsmasq.Name != "", assert.ObjectsAreEqual(volumes[len(volumes)-1], dnsmasqConfigVolume))
+		})
+	}
+}
+
+func TestStaticIPManagerContainer(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningInterface:   "eth1",
+			ProvisioningIP:          "fd00:1101::3",
+			ProvisioningNetworkCIDR: "fd00:1101::/64",
+		},
+	}
+	container := newStaticIPManagerContainer(prov, testImages)
+	assert.Equal(t, "fd00:1101::3/64", envValue(container, "PROVISIONING_IP"))
+	assert.Equal(t, "eth1", envValue(container, "PROVISIONING_INTERFACE"))
+}
+
+func TestDnsmasqContainerPorts(t *testing.T) {
+	testCases := []struct {
+		name         string
+		ip           string
+		expectedPort int32
+	}{
+		{name: "IPv4", ip: "172.30.20.3", expectedPort: 67},
+		{name: "IPv6", ip: "fd00:1101::3", expectedPort: 547},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{
+				Spec: metal3iov1alpha1.ProvisioningSpec{ProvisioningIP: tc.ip},
+			}
+			ports := newDnsmasqContainer(prov, testImages).Ports
+			if assert.Len(t, ports, 2) {
+				assert.Equal(t, tc.expectedPort, ports[0].ContainerPort)
+				assert.Equal(t, corev1.ProtocolUDP, ports[0].Protocol)
+				assert.Equal(t, int32(69), ports[1].ContainerPort)
+			}
+		})
+	}
+}
+
+func TestCredentialsWiring(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	containers := NewMetal3Deployment(prov, testImages, "openshift-machine-api").Spec.Template.Spec.Containers
+
+	mountPaths := func(c corev1.Container) []string {
+		paths := []string{}
+		for _, m := range c.VolumeMounts {
+			paths = append(paths, m.MountPath)
+		}
+		return paths
+	}
+	htpasswdSecret := func(c corev1.Container, name string) string {
+		for _, env := range c.Env {
+			if env.Name == name && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				assert.Equal(t, HtpasswdKey, env.ValueFrom.SecretKeyRef.Key)
+				return env.ValueFrom.SecretKeyRef.Name
+			}
+		}
+		return ""
+	}
+
+	bmo := findContainer(containers, "metal3-baremetal-operator")
+	assert.Equal(t, []string{"/opt/metal3/auth/ironic", "/opt/metal3/auth/ironic-inspector", "/opt/metal3/certs/ca"}, mountPaths(bmo))
+
+	ironic := findContainer(containers, "metal3-ironic")
+	assert.Equal(t, IronicCredentialsSecretName, htpasswdSecret(ironic, "IRONIC_HTPASSWD"))
+	assert.Contains(t, mountPaths(ironic), inspectorCredentialsPath)
+
+	inspector := findContainer(containers, "metal3-ironic-inspector")
+	assert.Equal(t, InspectorCredentialsSecretName, htpasswdSecret(inspector, "INSPECTOR_HTPASSWD"))
+	assert.Contains(t, mountPaths(inspector), ironicCredentialsPath)
+}
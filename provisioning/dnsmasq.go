@@ -0,0 +1,144 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+var dnsmasqTemplate = template.Must(template.New("dnsmasq.conf").Parse(`interface={{ .Interface }}
+bind-dynamic
+enable-tftp
+tftp-root=/shared/tftpboot
+
+# Disable listening for DNS
+port=0
+
+log-dhcp
+dhcp-range={{ .DHCPRange }}
+
+# Disable default router(s) and DNS over provisioning network
+dhcp-option=3
+dhcp-option=6
+{{- if .IPv6 }}
+
+# IPv6 hosts look for the DHCPv6 server in router advertisements
+enable-ra
+ra-param={{ .Interface }},0,0
+
+dhcp-vendorclass=set:pxe6,enterprise:343,PXEClient
+dhcp-userclass=set:ipxe6,iPXE
+dhcp-option=tag:pxe6,option6:bootfile-url,tftp://{{ .URLHost }}/snponly.efi
+dhcp-option=tag:ipxe6,option6:bootfile-url,{{ .BootURL }}
+{{- else }}
+
+dhcp-match=ipxe,175
+# Client is already running iPXE; move to next stage of chainloading
+dhcp-boot=tag:ipxe,{{ .BootURL }}
+
+dhcp-match=set:efi,option:client-arch,7
+dhcp-match=set:efi,option:client-arch,9
+dhcp-match=set:efi,option:client-arch,11
+# Client is PXE booting over EFI without iPXE ROM; send EFI version of iPXE chainloader
+dhcp-boot=tag:efi,tag:!ipxe,snponly.efi
+
+# Client is running PXE over BIOS; send BIOS version of iPXE chainloader
+dhcp-boot=/undionly.kpxe,,{{ .IP }}
+{{- end }}
+`))
+
+// DnsmasqConfig renders the dnsmasq configuration which serves DHCP, or
+// DHCPv6 when the network is IPv6, and chainloads iPXE on the
+// provisioning network. The spec is expected to be defaulted and valid.
+// The provisioning interface is left as a placeholder filled in by
+// DnsmasqCommand on each host.
+func DnsmasqConfig(prov *metal3iov1alpha1.Provisioning) (string, error) {
+	if !NewTopology(prov).Dnsmasq {
+		return "", fmt.Errorf("dnsmasq is not used when provisioningNetwork is %s", prov.NetworkMode())
+	}
+
+	ip := net.ParseIP(prov.Spec.ProvisioningIP)
+	if ip == nil {
+		return "", fmt.Errorf("could not parse provisioningIP %q", prov.Spec.ProvisioningIP)
+	}
+
+	data := struct {
+		Interface string
+		IP        string
+		URLHost   string
+		BootURL   string
+		DHCPRange string
+		IPv6      bool
+	}{
+		Interface: interfacePlaceholder,
+		IP:        ip.String(),
+		URLHost:   ip.String(),
+		BootURL:   HTTPURL(ip.String(), "/dualboot.ipxe"),
+		IPv6:      ip.To4() == nil,
+	}
+	if data.IPv6 {
+		data.URLHost = "[" + data.URLHost + "]"
+	}
+
+	dhcpRange, err := DHCPRange(prov)
+	if err != nil {
+		return "", err
+	}
+	data.DHCPRange = dhcpRange
+
+	var buf bytes.Buffer
+	if err := dnsmasqTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DnsmasqCommand returns the command which runs dnsmasq with the
+// configuration rendered by DnsmasqConfig, read from configPath, bound to
+// the provisioning interface of the host.
+func DnsmasqCommand(configPath string) []string {
+	return WithProvisioningInterface(fmt.Sprintf(
+		`sed "s/%s/$PROVISIONING_INTERFACE/g" %s > /tmp/dnsmasq.conf && exec /usr/sbin/dnsmasq --keep-in-foreground --log-facility=- --conf-file=/tmp/dnsmasq.conf`,
+		interfacePlaceholder, configPath))
+}
+
+// DHCPRange returns the DHCP range in the form expected by the
+// dhcp-range option of dnsmasq. DHCPv6 ranges also carry the prefix
+// length of the provisioning network.
+func DHCPRange(prov *metal3iov1alpha1.Provisioning) (string, error) {
+	addrs := strings.Split(prov.Spec.ProvisioningDHCPRange, ",")
+	if len(addrs) != 2 {
+		return "", fmt.Errorf("provisioningDHCPRange %q should be two comma separated IP addresses", prov.Spec.ProvisioningDHCPRange)
+	}
+	start := strings.TrimSpace(addrs[0])
+	end := strings.TrimSpace(addrs[1])
+
+	_, cidr, err := net.ParseCIDR(prov.Spec.ProvisioningNetworkCIDR)
+	if err != nil {
+		return "", fmt.Errorf("could not parse provisioningNetworkCIDR %q", prov.Spec.ProvisioningNetworkCIDR)
+	}
+	if cidr.IP.To4() != nil {
+		return fmt.Sprintf("%s,%s", start, end), nil
+	}
+	prefixLength, _ := cidr.Mask.Size()
+	return fmt.Sprintf("%s,%s,%d", start, end, prefixLength), nil
+}
@@ -0,0 +1,101 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestDnsmasqConfig(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          metal3iov1alpha1.ProvisioningSpec
+		expectedLines []string
+		absentLines   []string
+		expectedError string
+	}{
+		{
+			name: "ManagedIPv4",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ProvisioningDHCPRange:   "172.30.20.10, 172.30.20.100",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedLines: []string{
+				"interface=" + interfacePlaceholder,
+				"dhcp-range=172.30.20.10,172.30.20.100",
+				"dhcp-boot=tag:ipxe,http://172.30.20.3:6180/dualboot.ipxe",
+				"dhcp-boot=/undionly.kpxe,,172.30.20.3",
+			},
+			absentLines: []string{"enable-ra"},
+		},
+		{
+			name: "ManagedIPv6",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "fd00:1101::3",
+				ProvisioningNetworkCIDR: "fd00:1101::/64",
+				ProvisioningDHCPRange:   "fd00:1101::a,fd00:1101::ffff:ffff:ffff:fffe",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+			},
+			expectedLines: []string{
+				"dhcp-range=fd00:1101::a,fd00:1101::ffff:ffff:ffff:fffe,64",
+				"enable-ra",
+				"dhcp-option=tag:pxe6,option6:bootfile-url,tftp://[fd00:1101::3]/snponly.efi",
+				"dhcp-option=tag:ipxe6,option6:bootfile-url,http://[fd00:1101::3]:6180/dualboot.ipxe",
+			},
+			absentLines: []string{"dhcp-match=ipxe,175"},
+		},
+		{
+			name: "UnmanagedIPv6",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:   "eth1",
+				ProvisioningIP:          "fd00:1101::3",
+				ProvisioningNetworkCIDR: "fd00:1101::/64",
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+			},
+			expectedError: "dnsmasq is not used when provisioningNetwork is Unmanaged",
+		},
+		{
+			name: "LegacyDHCPExternal",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:    "eth1",
+				ProvisioningIP:           "172.30.20.3",
+				ProvisioningNetworkCIDR:  "172.30.20.0/24",
+				ProvisioningDHCPExternal: true,
+			},
+			expectedError: "dnsmasq is not used when provisioningNetwork is Unmanaged",
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			},
+			expectedError: "dnsmasq is not used when provisioningNetwork is Disabled",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+
+			config, err := DnsmasqConfig(prov)
+			if tc.expectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			for _, line := range tc.expectedLines {
+				assert.Contains(t, config, line+"\n")
+			}
+			for _, line := range tc.absentLines {
+				assert.NotContains(t, config, line)
+			}
+		})
+	}
+}
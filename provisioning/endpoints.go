@@ -0,0 +1,104 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provisioning renders the configuration of the metal3
+// services from a Provisioning spec. It does not talk to the cluster,
+// so the same output can be produced by the operator and offline.
+package provisioning
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// IronicPort is the port of the ironic API
+	IronicPort = 6385
+	// InspectorPort is the port of the ironic-inspector API
+	InspectorPort = 5050
+	// HTTPPort is the port of the httpd serving images and iPXE scripts
+	HTTPPort = 6180
+	// ImageCachePort is the port of the image cache on the control
+	// plane nodes
+	ImageCachePort = 6181
+	// IronicProxyPort is the port of the proxy to the ironic API
+	// running on every node when the provisioning network is disabled
+	IronicProxyPort = 6388
+)
+
+// IronicIP returns the address the ironic and ironic-inspector APIs
+// listen on, which is on the machine network when the provisioning
+// network is disabled.
+func IronicIP(prov *metal3iov1alpha1.Provisioning) string {
+	if prov.NetworkMode() == metal3iov1alpha1.ProvisioningNetworkDisabled {
+		return prov.Spec.ExternalIronicIP
+	}
+	return prov.Spec.ProvisioningIP
+}
+
+// HTTPIP returns the address the httpd serving iPXE scripts and images
+// listens on, which is on the machine network when the provisioning
+// network is disabled.
+func HTTPIP(prov *metal3iov1alpha1.Provisioning) string {
+	if prov.NetworkMode() == metal3iov1alpha1.ProvisioningNetworkDisabled {
+		return prov.Spec.ExternalHTTPIP
+	}
+	return prov.Spec.ProvisioningIP
+}
+
+// IronicEndpoint returns the URL of the ironic API listening on ip.
+func IronicEndpoint(ip string) string {
+	return endpointURL("https", ip, IronicPort, "/v1/")
+}
+
+// InspectorEndpoint returns the URL of the ironic-inspector API
+// listening on ip.
+func InspectorEndpoint(ip string) string {
+	return endpointURL("https", ip, InspectorPort, "/v1/")
+}
+
+// IronicServiceEndpoint returns the URL of the ironic API through its
+// Service in namespace.
+func IronicServiceEndpoint(namespace string) string {
+	return endpointURL("https", serviceHost(IronicServiceName, namespace), IronicPort, "/v1/")
+}
+
+// InspectorServiceEndpoint returns the URL of the ironic-inspector API
+// through its Service in namespace.
+func InspectorServiceEndpoint(namespace string) string {
+	return endpointURL("https", serviceHost(InspectorServiceName, namespace), InspectorPort, "/v1/")
+}
+
+// HTTPURL returns the URL of path on the httpd listening on ip. It is
+// plain http, as the firmware of the hosts fetches iPXE scripts and
+// images from it.
+func HTTPURL(ip, path string) string {
+	return endpointURL("http", ip, HTTPPort, path)
+}
+
+// endpointURL joins ip and port into a URL, which puts IPv6 addresses
+// between brackets.
+func endpointURL(scheme, ip string, port int, path string) string {
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(ip, strconv.Itoa(port)), path)
+}
+
+// serviceHost returns the name the Service named name in namespace
+// resolves as in the cluster, which the serving certificate is valid for.
+func serviceHost(name, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc", name, namespace)
+}
@@ -0,0 +1,83 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestServiceIPs(t *testing.T) {
+	testCases := []struct {
+		name           string
+		spec           metal3iov1alpha1.ProvisioningSpec
+		expectedIronic string
+		expectedHTTP   string
+	}{
+		{
+			name: "Managed",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningIP:   "172.30.20.3",
+				ExternalIronicIP: "192.168.111.10",
+			},
+			expectedIronic: "172.30.20.3",
+			expectedHTTP:   "172.30.20.3",
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningIP:      "172.30.20.3",
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+				ExternalHTTPIP:      "192.168.111.11",
+			},
+			expectedIronic: "192.168.111.10",
+			expectedHTTP:   "192.168.111.11",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+			assert.Equal(t, tc.expectedIronic, IronicIP(prov))
+			assert.Equal(t, tc.expectedHTTP, HTTPIP(prov))
+		})
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	testCases := []struct {
+		name              string
+		ip                string
+		expectedIronic    string
+		expectedInspector string
+		expectedHTTP      string
+	}{
+		{
+			name:              "IPv4",
+			ip:                "172.30.20.3",
+			expectedIronic:    "https://172.30.20.3:6385/v1/",
+			expectedInspector: "https://172.30.20.3:5050/v1/",
+			expectedHTTP:      "http://172.30.20.3:6180/images/rhcos.qcow2",
+		},
+		{
+			name:              "IPv6",
+			ip:                "fd00:1101::3",
+			expectedIronic:    "https://[fd00:1101::3]:6385/v1/",
+			expectedInspector: "https://[fd00:1101::3]:5050/v1/",
+			expectedHTTP:      "http://[fd00:1101::3]:6180/images/rhcos.qcow2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedIronic, IronicEndpoint(tc.ip))
+			assert.Equal(t, tc.expectedInspector, InspectorEndpoint(tc.ip))
+			assert.Equal(t, tc.expectedHTTP, HTTPURL(tc.ip, "/images/rhcos.qcow2"))
+		})
+	}
+}
+
+func TestServiceEndpoints(t *testing.T) {
+	assert.Equal(t, "https://metal3-ironic.openshift-machine-api.svc:6385/v1/", IronicServiceEndpoint("openshift-machine-api"))
+	assert.Equal(t, "https://metal3-ironic-inspector.openshift-machine-api.svc:5050/v1/", InspectorServiceEndpoint("openshift-machine-api"))
+}
@@ -0,0 +1,153 @@
+package provisioning
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the rendered manifests")
+
+// goldenOSDownloadURL is the OS image of the specs which cache one.
+const goldenOSDownloadURL = "https://releases.example.com/rhcos/rhcos-46.82.202010011740-0-openstack.x86_64.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7"
+
+// goldenSpecs are rendered by TestManifestsGolden. Each of them is
+// compared with testdata/golden/<name>.yaml, which
+// go test ./provisioning -update rewrites.
+var goldenSpecs = map[string]metal3iov1alpha1.ProvisioningSpec{
+	"managed-ipv4": {
+		ProvisioningInterface:     "eth1",
+		ProvisioningIP:            "172.30.20.3",
+		ProvisioningNetworkCIDR:   "172.30.20.0/24",
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkManaged,
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+	},
+	"managed-ipv6": {
+		ProvisioningInterface:     "eth1",
+		ProvisioningIP:            "fd00:1101::3",
+		ProvisioningNetworkCIDR:   "fd00:1101::/64",
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkManaged,
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+	},
+	"unmanaged-ipv4": {
+		ProvisioningMacAddresses:  []string{"52:54:00:aa:bb:01", "52:54:00:aa:bb:02"},
+		ProvisioningIP:            "172.30.20.3",
+		ProvisioningNetworkCIDR:   "172.30.20.0/24",
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+	},
+	"unmanaged-ipv6": {
+		ProvisioningMacAddresses: []string{"52:54:00:aa:bb:01", "52:54:00:aa:bb:02"},
+		ProvisioningIP:           "fd00:1101::3",
+		ProvisioningNetworkCIDR:  "fd00:1101::/64",
+		ProvisioningNetwork:      metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+	},
+	"disabled-ipv4": {
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkDisabled,
+		ExternalIronicIP:          "192.168.111.10",
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+	},
+	"disabled-ipv6": {
+		ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+		ExternalIronicIP:    "fd2e:6f44:5dd8:c956::14",
+		ExternalHTTPIP:      "fd2e:6f44:5dd8:c956::15",
+	},
+	"managed-ipv4-proxy": {
+		ProvisioningInterface:     "eth1",
+		ProvisioningIP:            "172.30.20.3",
+		ProvisioningNetworkCIDR:   "172.30.20.0/24",
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkManaged,
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+	},
+	"disabled-ipv6-proxy": {
+		ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+		ExternalIronicIP:    "fd2e:6f44:5dd8:c956::14",
+		ExternalHTTPIP:      "fd2e:6f44:5dd8:c956::15",
+	},
+	"unmanaged-ipv4-placement": {
+		ProvisioningMacAddresses:  []string{"52:54:00:aa:bb:01", "52:54:00:aa:bb:02"},
+		ProvisioningIP:            "172.30.20.3",
+		ProvisioningNetworkCIDR:   "172.30.20.0/24",
+		ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkUnmanaged,
+		ProvisioningOSDownloadURL: goldenOSDownloadURL,
+		NodePlacement: &metal3iov1alpha1.NodePlacement{
+			NodeSelector: map[string]string{"example.com/provisioning": "true"},
+			Tolerations: []corev1.Toleration{
+				{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/edge", Operator: corev1.TolerationOpEqual, Value: "provisioning", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	},
+}
+
+// goldenProxies are the cluster-wide proxies the goldenSpecs of the same
+// name are rendered with, the others are rendered without any.
+var goldenProxies = map[string]*Proxy{
+	"managed-ipv4-proxy": {
+		HTTPProxy:       "http://proxy.example.com:3128",
+		HTTPSProxy:      "http://proxy.example.com:3128",
+		NoProxy:         ".cluster.local,.svc,10.128.0.0/14,172.30.0.0/16,api-int.ostest.test.metalkube.org,localhost",
+		TrustedCABundle: true,
+	},
+	"disabled-ipv6-proxy": {
+		HTTPSProxy: "http://[fd2e:6f44:5dd8:c956::1]:3128",
+		NoProxy:    ".cluster.local,.svc,fd01::/48,fd02::/112",
+	},
+}
+
+// renderGolden renders the manifests of spec behind proxy as a YAML stream.
+func renderGolden(t *testing.T, spec metal3iov1alpha1.ProvisioningSpec, proxy *Proxy) []byte {
+	prov := &metal3iov1alpha1.Provisioning{Spec: spec}
+	prov.SetDefaults()
+	if !assert.NoError(t, prov.ValidateBaremetalProvisioningConfig()) {
+		return nil
+	}
+	objects, err := Manifests(prov, testImages, proxy, "openshift-machine-api")
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme.Scheme)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		data, err := yaml.Marshal(obj)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func TestManifestsGolden(t *testing.T) {
+	for name, spec := range goldenSpecs {
+		t.Run(name, func(t *testing.T) {
+			rendered := renderGolden(t, spec, goldenProxies[name])
+			path := filepath.Join("testdata", "golden", name+".yaml")
+
+			if *update {
+				assert.NoError(t, ioutil.WriteFile(path, rendered, 0644))
+				return
+			}
+			golden, err := ioutil.ReadFile(path)
+			if assert.NoError(t, err, "run go test ./provisioning -update to create it") {
+				assert.Equal(t, string(golden), string(rendered), "run go test ./provisioning -update to accept the changes")
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// ImageCacheDaemonSetName is the name of the DaemonSet caching the
+	// OS image on the control plane nodes
+	ImageCacheDaemonSetName = "metal3-image-cache"
+
+	// ImageCacheDownloadContainer is the init container of the image
+	// cache pods downloading and verifying the OS image. It publishes its
+	// progress on its pod, with the permissions of the
+	// ImageCacheServiceAccountName ServiceAccount.
+	ImageCacheDownloadContainer  = "metal3-image-cache-download"
+	ImageCacheServiceAccountName = "metal3-image-cache"
+
+	imageCacheVolume   = "metal3-image-cache"
+	imageCacheHostPath = "/var/lib/metal3/image-cache"
+	imageCachePath     = "/shared/html/images"
+)
+
+// ImageCacheLabels select the pods of the image cache DaemonSet.
+var ImageCacheLabels = map[string]string{
+	"k8s-app": ImageCacheDaemonSetName,
+}
+
+// ImageCacheURL returns the URL of the OS image on the image cache
+// listening on ip. It keeps the query of ProvisioningOSDownloadURL, so
+// that the checksum it carries can still be verified.
+func ImageCacheURL(prov *metal3iov1alpha1.Provisioning, ip string) string {
+	u := url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(ip, strconv.Itoa(ImageCachePort)),
+		Path:   path.Join("/images", imageFileName(prov)),
+	}
+	if source, err := url.Parse(prov.Spec.ProvisioningOSDownloadURL); err == nil {
+		u.RawQuery = source.RawQuery
+	}
+	return u.String()
+}
+
+// imageFileName returns the name of the file the OS image is downloaded
+// to, the last element of the path of ProvisioningOSDownloadURL.
+func imageFileName(prov *metal3iov1alpha1.Provisioning) string {
+	source, err := url.Parse(prov.Spec.ProvisioningOSDownloadURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(source.Path)
+}
+
+// NewImageCacheManifests returns the image cache DaemonSet, and the
+// ServiceAccount, Role and RoleBinding allowing its pods to publish the
+// progress of their download on themselves.
+func NewImageCacheManifests(prov *metal3iov1alpha1.Provisioning, images *Images, namespace string) []runtime.Object {
+	meta := metav1.ObjectMeta{
+		Name:      ImageCacheServiceAccountName,
+		Namespace: namespace,
+		Labels:    ImageCacheLabels,
+	}
+	return []runtime.Object{
+		&corev1.ServiceAccount{ObjectMeta: meta},
+		&rbacv1.Role{
+			ObjectMeta: meta,
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{""},
+					Resources: []string{"pods"},
+					Verbs:     []string{"get", "patch"},
+				},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     ImageCacheServiceAccountName,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      ImageCacheServiceAccountName,
+					Namespace: namespace,
+				},
+			},
+		},
+		NewImageCacheDaemonSet(prov, images, namespace),
+	}
+}
+
+// NewImageCacheDaemonSet returns the DaemonSet downloading the OS image
+// once on each control plane node, verifying it against the sha256
+// carried by the query of ProvisioningOSDownloadURL, and serving it over
+// HTTP on all the addresses of the node.
+func NewImageCacheDaemonSet(prov *metal3iov1alpha1.Provisioning, images *Images, namespace string) *appsv1.DaemonSet {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	cacheMount := corev1.VolumeMount{Name: imageCacheVolume, MountPath: imageCachePath}
+	// The cache on the host is only writable by root
+	root := int64(0)
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ImageCacheDaemonSetName,
+			Namespace: namespace,
+			Labels:    ImageCacheLabels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ImageCacheLabels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ImageCacheLabels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: ImageCacheServiceAccountName,
+					HostNetwork:        true,
+					DNSPolicy:          corev1.DNSClusterFirstWithHostNet,
+					NodeSelector:       nodeSelector(prov),
+					Tolerations:        tolerations(prov),
+					PriorityClassName:  nodePriorityClassName,
+					InitContainers: []corev1.Container{
+						{
+							Name:    ImageCacheDownloadContainer,
+							Image:   images.ClusterBaremetalOperator,
+							Command: []string{"/manager", "download"},
+							Args: []string{
+								"--url=" + prov.Spec.ProvisioningOSDownloadURL,
+								"--sha256=" + prov.OSImageSHA256(),
+								"--dir=" + imageCachePath,
+							},
+							VolumeMounts: []corev1.VolumeMount{cacheMount},
+							Env: []corev1.EnvVar{
+								{
+									Name: "POD_NAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+									},
+								},
+								{
+									Name: "POD_NAMESPACE",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+									},
+								},
+							},
+							SecurityContext: &corev1.SecurityContext{
+								RunAsUser: &root,
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:         "metal3-image-cache-httpd",
+							Image:        images.Ironic,
+							Command:      []string{"/bin/runhttpd"},
+							VolumeMounts: []corev1.VolumeMount{cacheMount},
+							Env: []corev1.EnvVar{
+								{Name: "HTTP_PORT", Value: strconv.Itoa(ImageCachePort)},
+								{Name: "LISTEN_ALL_INTERFACES", Value: "true"},
+							},
+							Ports: []corev1.ContainerPort{
+								{Name: "image-cache", ContainerPort: ImageCachePort},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: imageCacheVolume,
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: imageCacheHostPath,
+									Type: &hostPathType,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
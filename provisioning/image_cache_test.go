@@ -0,0 +1,84 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const testOSDownloadURL = "https://releases.example.com/rhcos/rhcos-46.82-openstack.x86_64.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7"
+
+func TestImageCacheURL(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{ProvisioningOSDownloadURL: testOSDownloadURL},
+	}
+	assert.Equal(t,
+		"http://172.30.20.3:6181/images/rhcos-46.82-openstack.x86_64.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7",
+		ImageCacheURL(prov, "172.30.20.3"))
+	assert.Equal(t,
+		"http://[fd00:1101::3]:6181/images/rhcos-46.82-openstack.x86_64.qcow2.gz?sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7",
+		ImageCacheURL(prov, "fd00:1101::3"))
+}
+
+func TestNewImageCacheManifests(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{ProvisioningOSDownloadURL: testOSDownloadURL},
+	}
+	objects := NewImageCacheManifests(prov, testImages, "openshift-machine-api")
+	if !assert.Len(t, objects, 4) {
+		return
+	}
+
+	// The pods may only publish their progress
+	role := objects[1].(*rbacv1.Role)
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "patch"}},
+	}, role.Rules)
+	binding := objects[2].(*rbacv1.RoleBinding)
+	assert.Equal(t, role.Name, binding.RoleRef.Name)
+	assert.Equal(t, objects[0].(*corev1.ServiceAccount).Name, binding.Subjects[0].Name)
+	assert.Equal(t, "openshift-machine-api", binding.Subjects[0].Namespace)
+	assert.Equal(t, binding.Subjects[0].Name, objects[3].(*appsv1.DaemonSet).Spec.Template.Spec.ServiceAccountName)
+}
+
+func TestNewImageCacheDaemonSet(t *testing.T) {
+	envOf := func(c corev1.Container) map[string]string {
+		env := map[string]string{}
+		for _, e := range c.Env {
+			env[e.Name] = e.Value
+		}
+		return env
+	}
+
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{ProvisioningOSDownloadURL: testOSDownloadURL},
+	}
+	spec := NewImageCacheDaemonSet(prov, testImages, "openshift-machine-api").Spec.Template.Spec
+
+	assert.True(t, spec.HostNetwork)
+	assert.Contains(t, spec.NodeSelector, "node-role.kubernetes.io/master")
+	assert.Equal(t, ImageCacheServiceAccountName, spec.ServiceAccountName)
+	if assert.Len(t, spec.InitContainers, 1) {
+		download := findContainer(spec.InitContainers, ImageCacheDownloadContainer)
+		assert.Equal(t, testImages.ClusterBaremetalOperator, download.Image)
+		assert.Equal(t, []string{
+			"--url=" + testOSDownloadURL,
+			"--sha256=c5bd3f6ea9e5e3a6b8d2a1b1a7f3d7e0e2c9a2e0f8c4b6d1e3f5a7b9c1d3e5f7",
+			"--dir=/shared/html/images",
+		}, download.Args)
+	}
+	if assert.Len(t, spec.Containers, 1) {
+		assert.Equal(t, int32(ImageCachePort), spec.Containers[0].Ports[0].ContainerPort)
+	}
+
+	// The metal3 pod downloads the image from the cache of its node
+	deployment := NewMetal3Deployment(prov, testImages, "openshift-machine-api").Spec.Template.Spec
+	downloader := findContainer(deployment.InitContainers, "metal3-machine-os-downloader")
+	assert.Equal(t, ImageCacheURL(prov, "localhost"), envOf(downloader)["RHCOS_IMAGE_URL"])
+	assert.Equal(t, spec.NodeSelector, deployment.NodeSelector)
+}
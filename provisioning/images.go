@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// ImagesConfigMapKey is the key of the images JSON in the ConfigMap
+// built from the release payload.
+const ImagesConfigMapKey = "images.json"
+
+// Images are the pull specs of the container images of the metal3
+// services, as listed in the images JSON of the release payload.
+type Images struct {
+	// ClusterBaremetalOperator is the image of the operator itself,
+	// which also downloads the OS image into the image cache
+	ClusterBaremetalOperator string `json:"clusterBaremetalOperator"`
+	BaremetalOperator        string `json:"baremetalOperator"`
+	Ironic                   string `json:"baremetalIronic"`
+	IronicInspector          string `json:"baremetalIronicInspector"`
+	IpaDownloader            string `json:"baremetalIpaDownloader"`
+	MachineOsDownloader      string `json:"baremetalMachineOsDownloader"`
+	StaticIPManager          string `json:"baremetalStaticIpManager"`
+}
+
+// imageReference matches a pull spec made of an optional registry, a
+// repository path and a tag, a digest or both.
+var imageReference = regexp.MustCompile(`^([a-zA-Z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+// LoadImages reads the images JSON at path and validates it.
+func LoadImages(path string) (*Images, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read images file: %v", err)
+	}
+	return ParseImages(data)
+}
+
+// ParseImages decodes and validates an images JSON.
+func ParseImages(data []byte) (*Images, error) {
+	images := &Images{}
+	if err := json.Unmarshal(data, images); err != nil {
+		return nil, fmt.Errorf("unable to parse images file: %v", err)
+	}
+	if err := images.Validate(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// Validate checks that every image is set to a well formed pull spec.
+func (images *Images) Validate() error {
+	var errs []error
+	for _, image := range []struct {
+		key  string
+		spec string
+	}{
+		{"clusterBaremetalOperator", images.ClusterBaremetalOperator},
+		{"baremetalOperator", images.BaremetalOperator},
+		{"baremetalIronic", images.Ironic},
+		{"baremetalIronicInspector", images.IronicInspector},
+		{"baremetalIpaDownloader", images.IpaDownloader},
+		{"baremetalMachineOsDownloader", images.MachineOsDownloader},
+		{"baremetalStaticIpManager", images.StaticIPManager},
+	} {
+		switch {
+		case image.spec == "":
+			errs = append(errs, fmt.Errorf("image %s is missing", image.key))
+		case !imageReference.MatchString(image.spec):
+			errs = append(errs, fmt.Errorf("image %s %q is not a valid pull spec", image.key, image.spec))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,114 @@
+package provisioning
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testImagesJSON = `{
+  "clusterBaremetalOperator": "quay.io/openshift/origin-cluster-baremetal-operator:latest",
+  "baremetalOperator": "quay.io/openshift/origin-baremetal-operator:latest",
+  "baremetalIronic": "quay.io/openshift/origin-ironic:latest",
+  "baremetalIronicInspector": "quay.io/openshift/origin-ironic-inspector:latest",
+  "baremetalIpaDownloader": "quay.io/openshift/origin-ironic-ipa-downloader:latest",
+  "baremetalMachineOsDownloader": "quay.io/openshift/origin-ironic-machine-os-downloader:latest",
+  "baremetalStaticIpManager": "quay.io/openshift/origin-ironic-static-ip-manager:latest"
+}`
+
+func TestParseImages(t *testing.T) {
+	images, err := ParseImages([]byte(testImagesJSON))
+	if assert.NoError(t, err) {
+		assert.Equal(t, testImages, images)
+	}
+
+	_, err = ParseImages([]byte(`{"baremetalIronic": `))
+	assert.Error(t, err)
+}
+
+func TestImagesValidate(t *testing.T) {
+	digest := "@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	testCases := []struct {
+		name          string
+		image         string
+		expectedError string
+	}{
+		{
+			name:  "Tag",
+			image: "quay.io/openshift/origin-ironic:4.6",
+		},
+		{
+			name:  "Digest",
+			image: "quay.io/openshift-release-dev/ocp-v4.0-art-dev" + digest,
+		},
+		{
+			name:  "RegistryPort",
+			image: "registry.example.com:5000/ocp/release:4.6.0-x86_64",
+		},
+		{
+			name:  "NoTag",
+			image: "ironic",
+		},
+		{
+			name:          "Missing",
+			image:         "",
+			expectedError: "image baremetalIronic is missing",
+		},
+		{
+			name:          "Uppercase",
+			image:         "quay.io/OpenShift/ironic:latest",
+			expectedError: "image baremetalIronic \"quay.io/OpenShift/ironic:latest\" is not a valid pull spec",
+		},
+		{
+			name:          "EmptyTag",
+			image:         "quay.io/openshift/ironic:",
+			expectedError: "is not a valid pull spec",
+		},
+		{
+			name:          "ShortDigest",
+			image:         "quay.io/openshift/ironic@sha256:0123",
+			expectedError: "is not a valid pull spec",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			images := *testImages
+			images.Ironic = tc.image
+
+			err := images.Validate()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestLoadImagesReportsAllMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ImagesConfigMapKey)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"baremetalIronic": "quay.io/openshift/origin-ironic:latest"}`), 0644))
+
+	_, err = LoadImages(path)
+	if assert.Error(t, err) {
+		for _, key := range []string{"clusterBaremetalOperator", "baremetalOperator", "baremetalIronicInspector", "baremetalIpaDownloader", "baremetalMachineOsDownloader", "baremetalStaticIpManager"} {
+			assert.Contains(t, err.Error(), "image "+key+" is missing")
+		}
+		assert.NotContains(t, err.Error(), "baremetalIronic ")
+	}
+
+	_, err = LoadImages(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// interfaceEnvVar and macsEnvVar pass the provisioning interface
+	// selection to the containers which need to find the NIC.
+	interfaceEnvVar = "PROVISIONING_INTERFACE"
+	macsEnvVar      = "PROVISIONING_MACS"
+
+	// interfacePlaceholder stands for the provisioning interface in
+	// rendered configuration files, as its name is only known once the
+	// pod runs on a host.
+	interfacePlaceholder = "@PROVISIONING_INTERFACE@"
+)
+
+// resolveInterfaceScript sets PROVISIONING_INTERFACE to the name of the
+// first NIC of the host whose MAC address is in PROVISIONING_MACS,
+// leaving it unchanged when no MAC address is given.
+const resolveInterfaceScript = `if [ -n "$PROVISIONING_MACS" ]; then
+  PROVISIONING_INTERFACE=
+  for mac in $(echo "$PROVISIONING_MACS" | tr ',' ' '); do
+    for dev in /sys/class/net/*; do
+      if [ "$(cat "$dev/address")" = "$mac" ]; then
+        PROVISIONING_INTERFACE=$(basename "$dev")
+        break 2
+      fi
+    done
+  done
+fi
+if [ -z "$PROVISIONING_INTERFACE" ]; then
+  echo "no interface matches PROVISIONING_MACS $PROVISIONING_MACS" >&2
+  exit 1
+fi
+export PROVISIONING_INTERFACE
+`
+
+// ProvisioningInterfaceEnv returns the environment the commands built by
+// WithProvisioningInterface use to find the provisioning NIC. It is empty
+// when there is no provisioning network.
+func ProvisioningInterfaceEnv(prov *metal3iov1alpha1.Provisioning) []corev1.EnvVar {
+	if !prov.ProvisioningInterfaceRequired() {
+		return nil
+	}
+
+	env := []corev1.EnvVar{
+		{Name: interfaceEnvVar, Value: prov.Spec.ProvisioningInterface},
+	}
+
+	// Normalize to the lower case, colon separated form of sysfs
+	macs := make([]string, 0, len(prov.Spec.ProvisioningMacAddresses))
+	for _, mac := range prov.Spec.ProvisioningMacAddresses {
+		if hw, err := net.ParseMAC(mac); err == nil {
+			macs = append(macs, hw.String())
+		}
+	}
+	if len(macs) > 0 {
+		env = append(env, corev1.EnvVar{Name: macsEnvVar, Value: strings.Join(macs, ",")})
+	}
+	return env
+}
+
+// WithProvisioningInterface wraps a shell command so that it runs with
+// PROVISIONING_INTERFACE set to the provisioning NIC of the host.
+func WithProvisioningInterface(command string) []string {
+	return []string{"/bin/sh", "-c", resolveInterfaceScript + command}
+}
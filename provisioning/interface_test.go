@@ -0,0 +1,69 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestProvisioningInterfaceEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     metal3iov1alpha1.ProvisioningSpec
+		expected []corev1.EnvVar
+	}{
+		{
+			name: "InterfaceName",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface: "eth1",
+			},
+			expected: []corev1.EnvVar{
+				{Name: "PROVISIONING_INTERFACE", Value: "eth1"},
+			},
+		},
+		{
+			name: "MacAddresses",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningMacAddresses: []string{"52:54:00:AA:BB:01", "52-54-00-aa-bb-02"},
+			},
+			expected: []corev1.EnvVar{
+				{Name: "PROVISIONING_INTERFACE", Value: ""},
+				{Name: "PROVISIONING_MACS", Value: "52:54:00:aa:bb:01,52:54:00:aa:bb:02"},
+			},
+		},
+		{
+			name: "InterfaceNameAndMacAddresses",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface:    "eth1",
+				ProvisioningMacAddresses: []string{"52:54:00:aa:bb:01"},
+			},
+			expected: []corev1.EnvVar{
+				{Name: "PROVISIONING_INTERFACE", Value: "eth1"},
+				{Name: "PROVISIONING_MACS", Value: "52:54:00:aa:bb:01"},
+			},
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningInterface: "eth1",
+				ProvisioningNetwork:   metal3iov1alpha1.ProvisioningNetworkDisabled,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+			assert.Equal(t, tc.expected, ProvisioningInterfaceEnv(prov))
+		})
+	}
+}
+
+func TestWithProvisioningInterface(t *testing.T) {
+	command := WithProvisioningInterface("exec /set-static-ip")
+	assert.Equal(t, []string{"/bin/sh", "-c"}, command[:2])
+	assert.Contains(t, command[2], "PROVISIONING_MACS")
+	assert.Regexp(t, "exec /set-static-ip$", command[2])
+}
@@ -0,0 +1,62 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// The metal3 pod provisions the hosts of the whole cluster, while
+	// the image cache and the ironic proxy serve their own node.
+	metal3PriorityClassName = "system-cluster-critical"
+	nodePriorityClassName   = "system-node-critical"
+)
+
+// controlPlaneNodeSelector and controlPlaneTolerations place pods on the
+// control plane nodes, which are wired to the provisioning network.
+var (
+	controlPlaneNodeSelector = map[string]string{
+		"node-role.kubernetes.io/master": "",
+	}
+	controlPlaneTolerations = []corev1.Toleration{
+		{
+			Key:      "node-role.kubernetes.io/master",
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+	}
+)
+
+// nodeSelector returns the node selector of the metal3 workloads, the
+// one of the NodePlacement of prov, or the control plane nodes.
+func nodeSelector(prov *metal3iov1alpha1.Provisioning) map[string]string {
+	if placement := prov.Spec.NodePlacement; placement != nil && len(placement.NodeSelector) > 0 {
+		return placement.NodeSelector
+	}
+	return controlPlaneNodeSelector
+}
+
+// tolerations returns the tolerations of the metal3 workloads, the ones
+// of the NodePlacement of prov, or those of the control plane taints.
+func tolerations(prov *metal3iov1alpha1.Provisioning) []corev1.Toleration {
+	if placement := prov.Spec.NodePlacement; placement != nil && len(placement.Tolerations) > 0 {
+		return placement.Tolerations
+	}
+	return controlPlaneTolerations
+}
@@ -0,0 +1,88 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestNodePlacement(t *testing.T) {
+	edgeSelector := map[string]string{"example.com/provisioning": "true"}
+	edgeTolerations := []corev1.Toleration{
+		{Key: "example.com/edge", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+
+	testCases := []struct {
+		name                string
+		placement           *metal3iov1alpha1.NodePlacement
+		expectedSelector    map[string]string
+		expectedTolerations []corev1.Toleration
+	}{
+		{
+			name:                "Default",
+			expectedSelector:    map[string]string{"node-role.kubernetes.io/master": ""},
+			expectedTolerations: controlPlaneTolerations,
+		},
+		{
+			name:                "Empty",
+			placement:           &metal3iov1alpha1.NodePlacement{},
+			expectedSelector:    controlPlaneNodeSelector,
+			expectedTolerations: controlPlaneTolerations,
+		},
+		{
+			name:                "NodeSelector",
+			placement:           &metal3iov1alpha1.NodePlacement{NodeSelector: edgeSelector},
+			expectedSelector:    edgeSelector,
+			expectedTolerations: controlPlaneTolerations,
+		},
+		{
+			name:                "Tolerations",
+			placement:           &metal3iov1alpha1.NodePlacement{NodeSelector: edgeSelector, Tolerations: edgeTolerations},
+			expectedSelector:    edgeSelector,
+			expectedTolerations: edgeTolerations,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{
+				Spec: metal3iov1alpha1.ProvisioningSpec{
+					ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkDisabled,
+					ExternalIronicIP:          "192.168.111.10",
+					ProvisioningOSDownloadURL: testOSDownloadURL,
+					NodePlacement:             tc.placement,
+				},
+			}
+			objects, err := Manifests(prov, testImages, nil, "openshift-machine-api")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			placed := 0
+			for _, obj := range objects {
+				var spec corev1.PodSpec
+				switch o := obj.(type) {
+				case *appsv1.Deployment:
+					spec = o.Spec.Template.Spec
+					assert.Equal(t, "system-cluster-critical", spec.PriorityClassName)
+				case *appsv1.DaemonSet:
+					// The ironic proxy runs on every node
+					if o.Name != ImageCacheDaemonSetName {
+						continue
+					}
+					spec = o.Spec.Template.Spec
+					assert.Equal(t, "system-node-critical", spec.PriorityClassName)
+				default:
+					continue
+				}
+				placed++
+				assert.Equal(t, tc.expectedSelector, spec.NodeSelector)
+				assert.Equal(t, tc.expectedTolerations, spec.Tolerations)
+			}
+			assert.Equal(t, 2, placed)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// TrustedCABundleConfigMapName is the name of the ConfigMap the
+	// cluster network operator fills with the CA certificates trusted
+	// cluster-wide, the ones of the proxy included
+	TrustedCABundleConfigMapName = "metal3-trusted-ca-bundle"
+	// TrustedCABundleKey is the key of the bundle in that ConfigMap
+	TrustedCABundleKey = "ca-bundle.crt"
+
+	injectTrustedCABundleLabel = "config.openshift.io/inject-trusted-cabundle"
+	trustedCABundleVolume      = "metal3-trusted-ca-bundle"
+	// Where RHEL based images look for the CA certificates they trust
+	trustedCABundlePath = "/etc/pki/ca-trust/extracted/pem"
+	trustedCABundleFile = "tls-ca-bundle.pem"
+)
+
+// Proxy is the cluster-wide proxy configuration the metal3 containers
+// use to reach outside of the cluster, as found in the status of the
+// cluster Proxy config.
+type Proxy struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// TrustedCABundle is set once the trusted CA bundle ConfigMap was
+	// filled in, and so can be mounted in place of the CA certificates
+	// of the images
+	TrustedCABundle bool
+}
+
+// NewTrustedCABundleConfigMap returns the ConfigMap the cluster network
+// operator injects the trusted CA bundle into.
+func NewTrustedCABundleConfigMap(namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TrustedCABundleConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				injectTrustedCABundleLabel: "true",
+			},
+		},
+	}
+}
+
+// setProxy makes all the containers of spec go through proxy, and trust
+// the CA certificates trusted cluster-wide. Nothing changes when proxy
+// is nil.
+func setProxy(spec *corev1.PodSpec, prov *metal3iov1alpha1.Provisioning, proxy *Proxy, namespace string) {
+	if proxy == nil {
+		return
+	}
+
+	env := []corev1.EnvVar{}
+	if proxy.HTTPProxy != "" {
+		env = append(env, corev1.EnvVar{Name: "HTTP_PROXY", Value: proxy.HTTPProxy})
+	}
+	if proxy.HTTPSProxy != "" {
+		env = append(env, corev1.EnvVar{Name: "HTTPS_PROXY", Value: proxy.HTTPSProxy})
+	}
+	if len(env) > 0 {
+		env = append(env, corev1.EnvVar{Name: "NO_PROXY", Value: noProxy(prov, proxy, namespace)})
+	}
+	mount := corev1.VolumeMount{Name: trustedCABundleVolume, MountPath: trustedCABundlePath, ReadOnly: true}
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			containers[i].Env = append(containers[i].Env, env...)
+			if proxy.TrustedCABundle {
+				containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+			}
+		}
+	}
+	if proxy.TrustedCABundle {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: trustedCABundleVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: TrustedCABundleConfigMapName},
+					Items: []corev1.KeyToPath{
+						{Key: TrustedCABundleKey, Path: trustedCABundleFile},
+					},
+				},
+			},
+		})
+	}
+}
+
+// noProxy returns the NO_PROXY list of proxy, to which the provisioning
+// network, the addresses of the ironic and ironic-inspector APIs and the
+// image cache of the node are added, as they are never reached through
+// the proxy.
+func noProxy(prov *metal3iov1alpha1.Provisioning, proxy *Proxy, namespace string) string {
+	entries := []string{}
+	seen := map[string]bool{}
+	add := func(entry string) {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, entry := range strings.Split(proxy.NoProxy, ",") {
+		add(entry)
+	}
+	if prov.NetworkMode() != metal3iov1alpha1.ProvisioningNetworkDisabled {
+		add(prov.Spec.ProvisioningNetworkCIDR)
+	}
+	for _, host := range ServingHosts(prov, namespace) {
+		add(host)
+	}
+	add(HTTPIP(prov))
+	add("localhost")
+	add("127.0.0.1")
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,96 @@
+package provisioning
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestNoProxy(t *testing.T) {
+	services := strings.Join([]string{
+		"metal3-ironic",
+		"metal3-ironic.openshift-machine-api",
+		"metal3-ironic.openshift-machine-api.svc",
+		"metal3-ironic.openshift-machine-api.svc.cluster.local",
+		"metal3-ironic-inspector",
+		"metal3-ironic-inspector.openshift-machine-api",
+		"metal3-ironic-inspector.openshift-machine-api.svc",
+		"metal3-ironic-inspector.openshift-machine-api.svc.cluster.local",
+	}, ",")
+
+	testCases := []struct {
+		name     string
+		spec     metal3iov1alpha1.ProvisioningSpec
+		noProxy  string
+		expected string
+	}{
+		{
+			name: "Managed",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkManaged,
+				ProvisioningIP:          "172.30.20.3",
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+			},
+			noProxy:  ".cluster.local,.svc,10.128.0.0/14, localhost",
+			expected: ".cluster.local,.svc,10.128.0.0/14,localhost,172.30.20.0/24,172.30.20.3," + services + ",127.0.0.1",
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork:     metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ProvisioningNetworkCIDR: "172.30.20.0/24",
+				ExternalIronicIP:        "fd2e:6f44:5dd8:c956::14",
+				ExternalHTTPIP:          "fd2e:6f44:5dd8:c956::15",
+			},
+			expected: "fd2e:6f44:5dd8:c956::14," + services + ",fd2e:6f44:5dd8:c956::15,localhost,127.0.0.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+			assert.Equal(t, tc.expected, noProxy(prov, &Proxy{NoProxy: tc.noProxy}, "openshift-machine-api"))
+		})
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	newSpec := func() *corev1.PodSpec {
+		return &corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "main"}},
+		}
+	}
+
+	// Without any proxy, nothing changes
+	spec := newSpec()
+	setProxy(spec, prov, nil, "openshift-machine-api")
+	assert.Equal(t, newSpec(), spec)
+	setProxy(spec, prov, &Proxy{NoProxy: ".svc"}, "openshift-machine-api")
+	assert.Equal(t, newSpec(), spec)
+
+	spec = newSpec()
+	setProxy(spec, prov, &Proxy{HTTPSProxy: "http://proxy.example.com:3128", TrustedCABundle: true}, "openshift-machine-api")
+	for _, container := range append(spec.InitContainers, spec.Containers...) {
+		if assert.Len(t, container.Env, 2, container.Name) {
+			assert.Equal(t, corev1.EnvVar{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"}, container.Env[0])
+			assert.Equal(t, "NO_PROXY", container.Env[1].Name)
+		}
+		assert.Equal(t, []corev1.VolumeMount{
+			{Name: trustedCABundleVolume, MountPath: "/etc/pki/ca-trust/extracted/pem", ReadOnly: true},
+		}, container.VolumeMounts, container.Name)
+	}
+	if assert.Len(t, spec.Volumes, 1) && assert.NotNil(t, spec.Volumes[0].ConfigMap) {
+		assert.Equal(t, TrustedCABundleConfigMapName, spec.Volumes[0].ConfigMap.Name)
+		assert.Equal(t, []corev1.KeyToPath{{Key: "ca-bundle.crt", Path: "tls-ca-bundle.pem"}}, spec.Volumes[0].ConfigMap.Items)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// EndpointsConfigMapName is the name of the ConfigMap publishing
+	// where the clients running in the cluster reach the APIs
+	EndpointsConfigMapName = "metal3-ironic-endpoints"
+	// The keys of the URLs of the APIs, and of the name of the
+	// ConfigMap holding the CA certificates to trust, in that ConfigMap
+	IronicEndpointKey            = "ironic"
+	InspectorEndpointKey         = "inspector"
+	CABundleConfigMapEndpointKey = "caBundleConfigMap"
+
+	// IronicProxyDaemonSetName is the name of the DaemonSet running the
+	// proxy to the ironic API on every node
+	IronicProxyDaemonSetName = "metal3-ironic-proxy"
+
+	servicePortName = "https"
+)
+
+// IronicProxyLabels select the pods of the ironic proxy DaemonSet.
+var IronicProxyLabels = map[string]string{
+	"k8s-app": IronicProxyDaemonSetName,
+}
+
+// NewServiceManifests returns the Services of the ironic and
+// ironic-inspector APIs and the ConfigMap publishing their URLs. The
+// metal3 pod uses the host network and the APIs only listen on the
+// ironic IP, so the Services come with Endpoints for that address
+// rather than selecting the pod. When the Topology includes the ironic
+// proxy, the ironic Service selects the proxy pods instead, so that it
+// is served by any node.
+func NewServiceManifests(prov *metal3iov1alpha1.Provisioning, namespace string) []runtime.Object {
+	ironic := newService(IronicServiceName, namespace, IronicPort)
+	objects := []runtime.Object{ironic}
+	if NewTopology(prov).IronicProxy {
+		ironic.Spec.Selector = IronicProxyLabels
+		ironic.Spec.Ports[0].TargetPort = intstr.FromInt(IronicProxyPort)
+	} else {
+		objects = append(objects, NewIronicEndpoints(prov, namespace))
+	}
+	objects = append(objects,
+		newService(InspectorServiceName, namespace, InspectorPort),
+		newEndpoints(InspectorServiceName, namespace, IronicIP(prov), InspectorPort),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      EndpointsConfigMapName,
+				Namespace: namespace,
+				Labels:    metal3Labels,
+			},
+			Data: map[string]string{
+				IronicEndpointKey:            IronicServiceEndpoint(namespace),
+				InspectorEndpointKey:         InspectorServiceEndpoint(namespace),
+				CABundleConfigMapEndpointKey: CABundleConfigMapName,
+			},
+		},
+	)
+	return objects
+}
+
+// NewIronicEndpoints returns the Endpoints of the ironic Service, which
+// only exist when the Service does not select the ironic proxy pods.
+func NewIronicEndpoints(prov *metal3iov1alpha1.Provisioning, namespace string) *corev1.Endpoints {
+	return newEndpoints(IronicServiceName, namespace, IronicIP(prov), IronicPort)
+}
+
+func newService(name, namespace string, port int) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    metal3Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       servicePortName,
+					Protocol:   corev1.ProtocolTCP,
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+				},
+			},
+		},
+	}
+}
+
+func newEndpoints(name, namespace, ip string, port int) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    metal3Labels,
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: ip}},
+				Ports: []corev1.EndpointPort{
+					{Name: servicePortName, Protocol: corev1.ProtocolTCP, Port: int32(port)},
+				},
+			},
+		},
+	}
+}
+
+// NewIronicProxyDaemonSet returns the DaemonSet running, on every node,
+// a proxy listening on IronicProxyPort of the host network which
+// forwards to the ironic API on the external ironic IP. It serves the
+// certificate of the API, which is valid for the name of the Service.
+func NewIronicProxyDaemonSet(prov *metal3iov1alpha1.Provisioning, images *Images, namespace string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      IronicProxyDaemonSetName,
+			Namespace: namespace,
+			Labels:    IronicProxyLabels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: IronicProxyLabels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: IronicProxyLabels,
+				},
+				Spec: corev1.PodSpec{
+					HostNetwork: true,
+					DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+					NodeSelector: map[string]string{
+						"kubernetes.io/os": "linux",
+					},
+					// Whatever the taints of the node
+					Tolerations: []corev1.Toleration{
+						{Operator: corev1.TolerationOpExists},
+					},
+					PriorityClassName: nodePriorityClassName,
+					Containers: []corev1.Container{
+						{
+							Name:         IronicProxyDaemonSetName,
+							Image:        images.Ironic,
+							Command:      []string{"/bin/runironic-proxy"},
+							VolumeMounts: []corev1.VolumeMount{tlsMount("ironic"), caBundleMount},
+							Env: []corev1.EnvVar{
+								{Name: "IRONIC_PROXY_PORT", Value: strconv.Itoa(IronicProxyPort)},
+								{Name: "IRONIC_UPSTREAM_IP", Value: IronicIP(prov)},
+								{Name: "IRONIC_UPSTREAM_PORT", Value: strconv.Itoa(IronicPort)},
+								{Name: "IRONIC_UPSTREAM_PROTO", Value: "https"},
+							},
+							Ports: []corev1.ContainerPort{
+								{Name: "ironic-proxy", ContainerPort: IronicProxyPort},
+							},
+						},
+					},
+					Volumes: tlsVolumes,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package provisioning
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestNewServiceManifests(t *testing.T) {
+	testCases := []struct {
+		name               string
+		spec               metal3iov1alpha1.ProvisioningSpec
+		expectedSelector   map[string]string
+		expectedTargetPort int
+		expectedEndpoints  map[string]string
+	}{
+		{
+			name: "Managed",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkManaged,
+				ProvisioningIP:      "172.30.20.3",
+			},
+			expectedTargetPort: 6385,
+			expectedEndpoints: map[string]string{
+				"metal3-ironic":           "172.30.20.3:6385",
+				"metal3-ironic-inspector": "172.30.20.3:5050",
+			},
+		},
+		{
+			// ironic is served by the proxy of every node
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "fd2e:6f44:5dd8:c956::14",
+			},
+			expectedSelector:   map[string]string{"k8s-app": "metal3-ironic-proxy"},
+			expectedTargetPort: 6388,
+			expectedEndpoints: map[string]string{
+				"metal3-ironic-inspector": "[fd2e:6f44:5dd8:c956::14]:5050",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+			services := map[string]*corev1.Service{}
+			endpoints := map[string]string{}
+			var cm *corev1.ConfigMap
+			for _, obj := range NewServiceManifests(prov, "openshift-machine-api") {
+				switch o := obj.(type) {
+				case *corev1.Service:
+					services[o.Name] = o
+				case *corev1.Endpoints:
+					if assert.Len(t, o.Subsets, 1) && assert.Len(t, o.Subsets[0].Addresses, 1) && assert.Len(t, o.Subsets[0].Ports, 1) {
+						subset := o.Subsets[0]
+						assert.Equal(t, "https", subset.Ports[0].Name)
+						endpoints[o.Name] = net.JoinHostPort(subset.Addresses[0].IP, strconv.Itoa(int(subset.Ports[0].Port)))
+					}
+				case *corev1.ConfigMap:
+					cm = o
+				}
+			}
+
+			if ironic := services["metal3-ironic"]; assert.NotNil(t, ironic) {
+				assert.Equal(t, tc.expectedSelector, ironic.Spec.Selector)
+				assert.Equal(t, []corev1.ServicePort{{
+					Name:       "https",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       6385,
+					TargetPort: intstr.FromInt(tc.expectedTargetPort),
+				}}, ironic.Spec.Ports)
+			}
+			if inspector := services["metal3-ironic-inspector"]; assert.NotNil(t, inspector) {
+				assert.Nil(t, inspector.Spec.Selector)
+			}
+			assert.Equal(t, tc.expectedEndpoints, endpoints)
+			if assert.NotNil(t, cm) {
+				assert.Equal(t, map[string]string{
+					"ironic":            "https://metal3-ironic.openshift-machine-api.svc:6385/v1/",
+					"inspector":         "https://metal3-ironic-inspector.openshift-machine-api.svc:5050/v1/",
+					"caBundleConfigMap": "metal3-ironic-ca-bundle",
+				}, cm.Data)
+			}
+		})
+	}
+}
+
+func TestNewIronicProxyDaemonSet(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	ds := NewIronicProxyDaemonSet(prov, testImages, "openshift-machine-api")
+	assert.Equal(t, IronicProxyLabels, ds.Spec.Template.Labels)
+
+	spec := ds.Spec.Template.Spec
+	assert.True(t, spec.HostNetwork)
+	assert.Equal(t, []corev1.Toleration{{Operator: corev1.TolerationOpExists}}, spec.Tolerations)
+	if assert.Len(t, spec.Containers, 1) {
+		container := spec.Containers[0]
+		assert.Equal(t, testImages.Ironic, container.Image)
+		assert.Equal(t, []corev1.EnvVar{
+			{Name: "IRONIC_PROXY_PORT", Value: "6388"},
+			{Name: "IRONIC_UPSTREAM_IP", Value: "192.168.111.10"},
+			{Name: "IRONIC_UPSTREAM_PORT", Value: "6385"},
+			{Name: "IRONIC_UPSTREAM_PROTO", Value: "https"},
+		}, container.Env)
+	}
+}
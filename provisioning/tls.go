@@ -0,0 +1,217 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+const (
+	// CASecretName is the name of the Secret holding the CA issuing the
+	// serving certificate of the ironic and ironic-inspector APIs
+	CASecretName = "metal3-ca"
+	// PreviousCACertKey is the key of the CA certificate that was
+	// replaced in that Secret, which clients keep trusting until it
+	// expires
+	PreviousCACertKey = "previous.crt"
+	// TLSSecretName is the name of the Secret holding the serving
+	// certificate of the ironic and ironic-inspector APIs
+	TLSSecretName = "metal3-ironic-tls"
+	// CABundleConfigMapName is the name of the ConfigMap publishing the
+	// CA certificates for the clients of the APIs
+	CABundleConfigMapName = "metal3-ironic-ca-bundle"
+	// CABundleKey is the key of the certificates in that ConfigMap
+	CABundleKey = "ca-bundle.crt"
+
+	// IronicServiceName and InspectorServiceName are the names of the
+	// Services of the APIs, which the serving certificate is valid for
+	IronicServiceName    = "metal3-ironic"
+	InspectorServiceName = "metal3-ironic-inspector"
+
+	tlsVolume      = "metal3-ironic-tls"
+	caBundleVolume = "metal3-ironic-ca-bundle"
+	caBundlePath   = "/certs/ca/ironic"
+	caBundleFile   = caBundlePath + "/" + corev1.TLSCertKey
+)
+
+// KeyPair is a PEM encoded certificate and its private key.
+type KeyPair struct {
+	Cert []byte
+	Key  []byte
+}
+
+// NewCA returns a self-signed CA valid from notBefore for validity.
+func NewCA(notBefore time.Time, validity time.Duration) (*KeyPair, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "metal3-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return newKeyPair(template, nil)
+}
+
+// Issue returns a serving certificate signed by ca for hosts, which are
+// IP addresses or DNS names, valid from notBefore for validity.
+func (ca *KeyPair) Issue(hosts []string, notBefore time.Time, validity time.Duration) (*KeyPair, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts to issue a certificate for")
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0]},
+		NotBefore:   notBefore,
+		NotAfter:    notBefore.Add(validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return newKeyPair(template, ca)
+}
+
+// newKeyPair generates a key and signs template with the key of issuer,
+// or with that key when issuer is nil.
+func newKeyPair(template *x509.Certificate, issuer *KeyPair) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate key: %v", err)
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate serial number: %v", err)
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		if parent, err = ParseCertificate(issuer.Cert); err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(issuer.Key)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded key found")
+		}
+		if signer, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("unable to parse key: %v", err)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign certificate for %s: %v", template.Subject.CommonName, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode key: %v", err)
+	}
+	return &KeyPair{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// ParseCertificate parses the first PEM encoded certificate of data.
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// ServingHosts returns the IP addresses and DNS names the ironic and
+// ironic-inspector APIs are reached at, which their serving certificate
+// must be valid for.
+func ServingHosts(prov *metal3iov1alpha1.Provisioning, namespace string) []string {
+	hosts := []string{}
+	for _, address := range []string{IronicIP(prov), prov.Spec.ProvisioningIP} {
+		// Written the way the certificate records it
+		ip := net.ParseIP(address)
+		if ip != nil && (len(hosts) == 0 || hosts[0] != ip.String()) {
+			hosts = append(hosts, ip.String())
+		}
+	}
+	for _, service := range []string{IronicServiceName, InspectorServiceName} {
+		hosts = append(hosts,
+			service,
+			fmt.Sprintf("%s.%s", service, namespace),
+			fmt.Sprintf("%s.%s.svc", service, namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+		)
+	}
+	return hosts
+}
+
+// tlsVolumes are the serving certificate of the APIs and the CA
+// certificates their clients trust.
+var tlsVolumes = []corev1.Volume{
+	{
+		Name: tlsVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: TLSSecretName},
+		},
+	},
+	{
+		Name: caBundleVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: CABundleConfigMapName},
+				Items: []corev1.KeyToPath{
+					{Key: CABundleKey, Path: corev1.TLSCertKey},
+				},
+			},
+		},
+	},
+}
+
+// tlsMount mounts the serving certificate where the ironic image looks
+// for the one of the API running in the container.
+func tlsMount(api string) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      tlsVolume,
+		MountPath: "/certs/" + api,
+		ReadOnly:  true,
+	}
+}
+
+var caBundleMount = corev1.VolumeMount{
+	Name:      caBundleVolume,
+	MountPath: caBundlePath,
+	ReadOnly:  true,
+}
@@ -0,0 +1,141 @@
+package provisioning
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestIssue(t *testing.T) {
+	now := time.Now()
+	ca, err := NewCA(now, 24*time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	serving, err := ca.Issue([]string{"172.30.20.3", "fd00:1101::3", "metal3-ironic.openshift-machine-api.svc"}, now, time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	caCert, err := ParseCertificate(ca.Cert)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, caCert.IsCA)
+	cert, err := ParseCertificate(serving.Cert)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.WithinDuration(t, now.Add(time.Hour), cert.NotAfter, time.Second)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	for _, host := range []string{"172.30.20.3", "fd00:1101::3", "metal3-ironic.openshift-machine-api.svc"} {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		assert.NoError(t, err, host)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "172.30.20.4", Roots: roots})
+	assert.Error(t, err)
+
+	// A certificate signed by another CA is not trusted
+	other, err := NewCA(now, 24*time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherCert, err := ParseCertificate(other.Cert)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherCert)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "172.30.20.3", Roots: otherRoots})
+	assert.Error(t, err)
+
+	_, err = ca.Issue(nil, now, time.Hour)
+	assert.Error(t, err)
+	_, err = ParseCertificate(ca.Key)
+	assert.Error(t, err)
+}
+
+func TestServingHosts(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          metal3iov1alpha1.ProvisioningSpec
+		expectedHosts []string
+	}{
+		{
+			name: "Managed",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkManaged,
+				ProvisioningIP:      "172.30.20.3",
+			},
+			expectedHosts: []string{"172.30.20.3"},
+		},
+		{
+			name: "Disabled",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ExternalIronicIP:    "192.168.111.10",
+			},
+			expectedHosts: []string{"192.168.111.10"},
+		},
+		{
+			name: "DisabledWithProvisioningIP",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ProvisioningIP:      "172.30.20.3",
+				ExternalIronicIP:    "192.168.111.10",
+			},
+			expectedHosts: []string{"192.168.111.10", "172.30.20.3"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hosts := ServingHosts(&metal3iov1alpha1.Provisioning{Spec: tc.spec}, "openshift-machine-api")
+			assert.Equal(t, tc.expectedHosts, hosts[:len(tc.expectedHosts)])
+			assert.Contains(t, hosts, "metal3-ironic.openshift-machine-api.svc")
+			assert.Contains(t, hosts, "metal3-ironic-inspector.openshift-machine-api.svc.cluster.local")
+		})
+	}
+}
+
+func TestTLSWiring(t *testing.T) {
+	prov := &metal3iov1alpha1.Provisioning{
+		Spec: metal3iov1alpha1.ProvisioningSpec{
+			ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled,
+			ExternalIronicIP:    "192.168.111.10",
+		},
+	}
+	containers := NewMetal3Deployment(prov, testImages, "openshift-machine-api").Spec.Template.Spec.Containers
+
+	for name, certPath := range map[string]string{
+		"metal3-ironic":           "/certs/ironic",
+		"metal3-ironic-inspector": "/certs/ironic-inspector",
+	} {
+		mounts := map[string]string{}
+		for _, m := range findContainer(containers, name).VolumeMounts {
+			mounts[m.MountPath] = m.Name
+		}
+		assert.Equal(t, tlsVolume, mounts[certPath], name)
+		assert.Equal(t, caBundleVolume, mounts[caBundlePath], name)
+	}
+
+	// The ramdisk trusts the CA too
+	env := map[string]string{}
+	for _, e := range findContainer(containers, "metal3-ironic").Env {
+		env[e.Name] = e.Value
+	}
+	assert.Equal(t, "/certs/ca/ironic/tls.crt", env["OS_AGENT__API_CA_FILE"])
+
+	bmo := findContainer(containers, "metal3-baremetal-operator")
+	env = map[string]string{}
+	for _, e := range bmo.Env {
+		env[e.Name] = e.Value
+	}
+	assert.Equal(t, "https://192.168.111.10:6385/v1/", env["IRONIC_ENDPOINT"])
+	assert.Equal(t, "/opt/metal3/certs/ca/tls.crt", env["IRONIC_CACERT_FILE"])
+}
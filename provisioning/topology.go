@@ -0,0 +1,52 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+// Topology lists the optional components of the metal3 services.
+// Ironic, ironic-inspector, httpd and mariadb always run.
+type Topology struct {
+	// Dnsmasq serves DHCP and TFTP on the provisioning network
+	Dnsmasq bool
+	// StaticIPManager keeps the ProvisioningIP on the provisioning
+	// interface of the host running the pod
+	StaticIPManager bool
+	// ImageCache caches the OS image on the control plane nodes
+	ImageCache bool
+	// IronicProxy forwards the ironic API from every node to the
+	// external ironic IP
+	IronicProxy bool
+}
+
+// NewTopology returns the containers needed in the effective network
+// mode of prov, which accounts for the legacy ProvisioningDHCPExternal
+// flag. dnsmasq only runs when the network is Managed, as DHCP is served
+// outside of the cluster when it is Unmanaged. static-ip-manager runs
+// unless the network is Disabled, since there is no provisioning
+// interface then. The image cache runs whenever there is an OS image to
+// download. The ironic proxy only runs when the network is Disabled, as
+// the external ironic IP is then the only address of the API.
+func NewTopology(prov *metal3iov1alpha1.Provisioning) Topology {
+	return Topology{
+		Dnsmasq:         prov.DHCPServer() == metal3iov1alpha1.DHCPServerInternal,
+		StaticIPManager: prov.ProvisioningInterfaceRequired(),
+		ImageCache:      prov.Spec.ProvisioningOSDownloadURL != "",
+		IronicProxy:     prov.NetworkMode() == metal3iov1alpha1.ProvisioningNetworkDisabled,
+	}
+}
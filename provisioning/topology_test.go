@@ -0,0 +1,57 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3iov1alpha1 "github.com/openshift/cluster-baremetal-operator/api/v1alpha1"
+)
+
+func TestNewTopology(t *testing.T) {
+	testCases := []struct {
+		name             string
+		spec             metal3iov1alpha1.ProvisioningSpec
+		expectedTopology Topology
+	}{
+		{
+			name:             "Managed",
+			spec:             metal3iov1alpha1.ProvisioningSpec{ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkManaged},
+			expectedTopology: Topology{Dnsmasq: true, StaticIPManager: true},
+		},
+		{
+			name:             "Unmanaged",
+			spec:             metal3iov1alpha1.ProvisioningSpec{ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkUnmanaged},
+			expectedTopology: Topology{StaticIPManager: true},
+		},
+		{
+			name:             "Disabled",
+			spec:             metal3iov1alpha1.ProvisioningSpec{ProvisioningNetwork: metal3iov1alpha1.ProvisioningNetworkDisabled},
+			expectedTopology: Topology{IronicProxy: true},
+		},
+		{
+			name:             "LegacyDHCPInternal",
+			spec:             metal3iov1alpha1.ProvisioningSpec{},
+			expectedTopology: Topology{Dnsmasq: true, StaticIPManager: true},
+		},
+		{
+			name:             "LegacyDHCPExternal",
+			spec:             metal3iov1alpha1.ProvisioningSpec{ProvisioningDHCPExternal: true},
+			expectedTopology: Topology{StaticIPManager: true},
+		},
+		{
+			name: "ImageCache",
+			spec: metal3iov1alpha1.ProvisioningSpec{
+				ProvisioningNetwork:       metal3iov1alpha1.ProvisioningNetworkDisabled,
+				ProvisioningOSDownloadURL: "https://releases.example.com/rhcos.qcow2.gz",
+			},
+			expectedTopology: Topology{ImageCache: true, IronicProxy: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &metal3iov1alpha1.Provisioning{Spec: tc.spec}
+			assert.Equal(t, tc.expectedTopology, NewTopology(prov))
+		})
+	}
+}